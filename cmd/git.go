@@ -0,0 +1,157 @@
+/*
+Copyright © 2025 Debarshi Basak
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+	"github.com/adaptive-scale/blacklight/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// gitCmd represents the git command
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Git server integrations",
+	Long: `Git server integrations that scan pushes before they are accepted.
+
+Examples:
+  # Reject pushes adding secrets, from hooks/pre-receive in a bare repository
+  exec blacklight git pre-receive
+
+  # Only reject high severity findings and allow at most 10 seconds per push
+  exec blacklight git pre-receive --severity 3 --timeout 10s`,
+}
+
+// preReceiveCmd represents the git pre-receive command
+var preReceiveCmd = &cobra.Command{
+	Use:   "pre-receive",
+	Short: "Scan pushed commits from a pre-receive hook and reject pushes adding secrets",
+	Long: `Scan the commits being pushed to a repository and reject the push when they add
+secrets. Run it from the hooks/pre-receive script of a bare repository; it reads
+"<old> <new> <ref>" lines on stdin as git passes them.
+
+Only commits that no existing ref reaches are scanned, so re-pushing or
+branching from known history costs nothing. Deleted refs are ignored. Reviewed
+lines can be marked with a "blacklight:allow" comment.
+
+If the scan does not finish within --timeout the push is accepted with a
+warning, unless --reject-on-timeout is set, so pushes never hang. Pushes are
+also accepted with a warning when ~/.blacklight/config.yaml does not exist,
+but rejected when it cannot be parsed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		severity, _ := cmd.Flags().GetInt("severity")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		rejectOnTimeout, _ := cmd.Flags().GetBool("reject-on-timeout")
+		repo, _ := cmd.Flags().GetString("repo")
+
+		updates, err := scanner.ParseRefUpdates(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		// Servers without rules accept pushes rather than rejecting them all
+		patterns, err := loadConfiguration()
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("blacklight: warning: %v, push accepted unscanned\n", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		gitScanner, err := scanner.NewGitScanner(repo, nil)
+		if err != nil {
+			return err
+		}
+		gitScanner.AddPattern(patterns...)
+
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		violations, err := gitScanner.ScanPush(ctx, updates)
+		if err != nil {
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return err
+			}
+			if rejectOnTimeout {
+				fmt.Printf("blacklight: push rejected, secret scan did not finish within %s\n", timeout)
+				os.Exit(1)
+			}
+			fmt.Printf("blacklight: warning: secret scan did not finish within %s, push accepted unscanned\n", timeout)
+			return nil
+		}
+
+		var blocking []model.Violation
+		for _, v := range violations {
+			if v.Rule.Severity >= severity {
+				blocking = append(blocking, v)
+			}
+		}
+		if len(blocking) == 0 {
+			return nil
+		}
+
+		printPushReport(blocking)
+		os.Exit(1)
+		return nil
+	},
+}
+
+// printPushReport prints the findings of a rejected push grouped by commit.
+// Matches are left out, as hook output is shown to the pusher and often logged.
+func printPushReport(violations []model.Violation) {
+	var commits []string
+	byCommit := make(map[string][]model.Violation)
+	for _, v := range violations {
+		if _, ok := byCommit[v.Commit]; !ok {
+			commits = append(commits, v.Commit)
+		}
+		byCommit[v.Commit] = append(byCommit[v.Commit], v)
+	}
+
+	fmt.Printf("blacklight: push rejected, %d secret(s) found in %d commit(s)\n", len(violations), len(commits))
+	for _, commit := range commits {
+		first := byCommit[commit][0]
+		fmt.Printf("\ncommit %s\nAuthor: %s\nDate:   %s\n", commit, first.Author, first.CommitDate)
+		for _, v := range byCommit[commit] {
+			fmt.Printf("  %s:%d: %s (%s)\n", v.Location, v.LineNum, v.Rule.Name, severityName(v.Rule.Severity))
+		}
+	}
+	fmt.Printf("\nRemove the secrets from these commits and push again, or mark reviewed lines with %q.\n", scanner.AllowComment)
+}
+
+// severityName returns the name of a severity level
+func severityName(level int) string {
+	switch level {
+	case 3:
+		return "High"
+	case 2:
+		return "Medium"
+	case 1:
+		return "Low"
+	default:
+		return fmt.Sprintf("Unknown (%d)", level)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(gitCmd)
+	gitCmd.AddCommand(preReceiveCmd)
+
+	preReceiveCmd.Flags().Int("severity", 1, "reject pushes with findings at or above this severity (1 low, 2 medium, 3 high)")
+	preReceiveCmd.Flags().Duration("timeout", 30*time.Second, "time budget for the scan, 0 for no limit")
+	preReceiveCmd.Flags().Bool("reject-on-timeout", false, "reject the push instead of accepting it when the scan times out")
+	preReceiveCmd.Flags().String("repo", ".", "repository receiving the push (git runs hooks inside it)")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 Debarshi Basak
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// hookMarker identifies hook scripts written by blacklight, so they can be
+// replaced without --force
+const hookMarker = "# Installed by blacklight"
+
+// preCommitHook is the git pre-commit hook script
+const preCommitHook = `#!/bin/sh
+` + hookMarker + `: blocks commits that add secrets.
+# Mark a reviewed line with "blacklight:allow", or bypass the hook with
+# git commit --no-verify.
+exec blacklight scan --staged
+`
+
+// hookCmd represents the hook command
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Manage git hooks",
+	Long: `Manage git hooks that scan changes before they are committed.
+
+Examples:
+  # Install a pre-commit hook in the current repository
+  blacklight hook install
+
+  # Replace an existing pre-commit hook
+  blacklight hook install --force`,
+}
+
+// installHookCmd represents the hook install command
+var installHookCmd = &cobra.Command{
+	Use:   "install [repository]",
+	Short: "Install a git pre-commit hook running blacklight scan --staged",
+	Long: `Install a git pre-commit hook that runs "blacklight scan --staged" and blocks
+commits that add secrets.
+
+Reviewed lines can be marked with a "blacklight:allow" comment, and the hook
+can be bypassed for a single commit with "git commit --no-verify".
+
+Projects using the pre-commit framework (https://pre-commit.com) can add this
+to .pre-commit-config.yaml instead:
+
+  repos:
+    - repo: https://github.com/adaptive-scale/blacklight
+      rev: <version>
+      hooks:
+        - id: blacklight`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		repo := "."
+		if len(args) > 0 {
+			repo = args[0]
+		}
+
+		// Respects core.hooksPath and worktrees
+		output, err := exec.Command("git", "-C", repo, "rev-parse", "--path-format=absolute", "--git-path", "hooks").Output()
+		if err != nil {
+			return fmt.Errorf("%s is not a git repository: %w", repo, err)
+		}
+		hooksDir := strings.TrimSpace(string(output))
+
+		hookPath := filepath.Join(hooksDir, "pre-commit")
+		if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), hookMarker) && !force {
+			return fmt.Errorf("a pre-commit hook already exists at %s, use --force to replace it", hookPath)
+		}
+
+		if err := os.MkdirAll(hooksDir, 0755); err != nil {
+			return fmt.Errorf("error creating hooks directory: %w", err)
+		}
+		if err := os.WriteFile(hookPath, []byte(preCommitHook), 0755); err != nil {
+			return fmt.Errorf("error writing hook: %w", err)
+		}
+
+		fmt.Println("=> installed pre-commit hook " + hookPath)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(installHookCmd)
+
+	installHookCmd.Flags().Bool("force", false, "replace an existing pre-commit hook")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as blacklight when BLACKLIGHT_TEST_ARGS is
+// set to its arguments, one per line, so git hooks installed by tests can call it
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("BLACKLIGHT_TEST_ARGS"); ok {
+		rootCmd.SetArgs(strings.Split(args, "\n"))
+		if err := rootCmd.Execute(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// installBlacklight puts a blacklight command running the test binary on PATH
+// and gives it a home directory whose configuration has an AWS key rule
+func installBlacklight(t *testing.T) {
+	t.Helper()
+	binary, err := os.Executable()
+	require.NoError(t, err)
+
+	bin := t.TempDir()
+	shim := "#!/bin/sh\nBLACKLIGHT_TEST_ARGS=\"$(printf '%s\\n' \"$@\")\" exec '" + binary + "'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "blacklight"), []byte(shim), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	home := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".blacklight"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".blacklight", "config.yaml"), []byte(`
+- id: aws-access-key
+  name: AWS Access Key
+  regex: AKIA[0-9A-Z]{16}
+  severity: 3
+`), 0644))
+	t.Setenv("HOME", home)
+}
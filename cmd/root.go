@@ -85,6 +85,37 @@ func loadRules() ([]model.Configuration, error) {
 	return rules, nil
 }
 
+// loadConfiguration loads and compiles the scan patterns from
+// ~/.blacklight/config.yaml, skipping rules whose regex does not compile
+func loadConfiguration() ([]model.Configuration, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(homeDir, ".blacklight", "config.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	var conf []model.Configuration
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	var patterns []model.Configuration
+	for _, c := range conf {
+		c.CompiledRegex, err = regexp.Compile(c.Regex)
+		if err != nil {
+			fmt.Printf("Error compiling regex for rule %s: %v\n", c.Name, err)
+			continue
+		}
+		patterns = append(patterns, c)
+	}
+
+	return patterns, nil
+}
+
 // printViolations prints the violations in a formatted way
 func printViolations(violations []model.Violation) {
 	red := color.New(color.FgRed).SprintFunc()
@@ -0,0 +1,41 @@
+// Package gittest builds git repositories for tests
+package gittest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Command returns a git command run in dir with a fixed identity, ignoring
+// user and system configuration
+func Command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+	return cmd
+}
+
+// Run runs git in dir and returns its trimmed output
+func Run(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	output, err := Command(dir, args...).CombinedOutput()
+	require.NoError(t, err, "git %s: %s", strings.Join(args, " "), output)
+	return strings.TrimSpace(string(output))
+}
+
+// CommitFile writes a file in the work tree at dir, commits it and returns
+// the commit
+func CommitFile(t testing.TB, dir, name, content string) string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	Run(t, dir, "add", name)
+	Run(t, dir, "commit", "-q", "-m", "add "+name)
+	return Run(t, dir, "rev-parse", "HEAD")
+}
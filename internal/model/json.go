@@ -0,0 +1,78 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// JSONResult represents a single violation in a JSON report
+type JSONResult struct {
+	Rule         string `json:"rule"`
+	RuleID       string `json:"ruleId,omitempty"`
+	Type         string `json:"type"`
+	Severity     int    `json:"severity"`
+	Match        string `json:"match"`
+	Location     string `json:"location"`
+	Path         string `json:"path,omitempty"`
+	Line         int    `json:"line,omitempty"`
+	StartColumn  int    `json:"startColumn,omitempty"`
+	EndColumn    int    `json:"endColumn,omitempty"`
+	Context      string `json:"context,omitempty"`
+	Commit       string `json:"commit,omitempty"`
+	Author       string `json:"author,omitempty"`
+	CommitDate   string `json:"commitDate,omitempty"`
+	Blob         string `json:"blob,omitempty"`
+	Reachability string `json:"reachability,omitempty"`
+	Query        string `json:"query,omitempty"`
+}
+
+// NewJSONResult converts a violation to its JSON report form
+func NewJSONResult(v Violation) JSONResult {
+	return JSONResult{
+		Rule:         v.Rule.Name,
+		RuleID:       v.Rule.ID,
+		Type:         v.Rule.Type,
+		Severity:     v.Rule.Severity,
+		Match:        v.Match,
+		Location:     v.Location,
+		Path:         v.Path,
+		Line:         v.LineNum,
+		StartColumn:  v.StartCol,
+		EndColumn:    v.EndCol,
+		Context:      v.Context,
+		Commit:       v.Commit,
+		Author:       v.Author,
+		CommitDate:   v.CommitDate,
+		Blob:         v.Blob,
+		Reachability: v.Reachability,
+		Query:        v.Query,
+	}
+}
+
+// GenerateJSON generates a JSON report from the violations
+func GenerateJSON(violations []Violation) {
+	results := make([]JSONResult, 0, len(violations))
+	for _, v := range violations {
+		results = append(results, NewJSONResult(v))
+	}
+
+	// Keep author emails readable instead of escaping <>
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		fmt.Printf("Error generating JSON report: %v\n", err)
+		return
+	}
+
+	err := os.WriteFile("blacklight-results.json", output.Bytes(), 0644)
+	if err != nil {
+		fmt.Printf("Error writing JSON report: %v\n", err)
+		return
+	}
+
+	fmt.Println("✅ JSON report generated: blacklight-results.json")
+}
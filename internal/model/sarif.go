@@ -60,16 +60,24 @@ type RuleProperties struct {
 
 // Result represents a single analysis result
 type Result struct {
-	RuleID    string      `json:"ruleId"`
-	RuleIndex int         `json:"ruleIndex"`
-	Level     string      `json:"level"`
-	Message   Message     `json:"message"`
-	Locations []Location  `json:"locations"`
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    Message           `json:"message"`
+	Locations  []Location        `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 // Location represents a location within an analyzed artifact
 type Location struct {
-	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+	PhysicalLocation PhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// LogicalLocation represents a structural location within an artifact,
+// such as a notebook cell
+type LogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
 }
 
 // PhysicalLocation represents a physical location within an artifact
@@ -183,6 +191,26 @@ func GenerateSARIF(violations []Violation) {
 			},
 		}
 
+		result.Properties = map[string]string{}
+		if v.Commit != "" {
+			result.Properties["commit"] = v.Commit
+			result.Properties["author"] = v.Author
+			result.Properties["commitDate"] = v.CommitDate
+		}
+		if v.Blob != "" {
+			result.Properties["blob"] = v.Blob
+			result.Properties["reachability"] = v.Reachability
+		}
+		if v.Query != "" {
+			result.Properties["query"] = v.Query
+		}
+
+		if v.Path != "" {
+			result.Locations[0].LogicalLocations = []LogicalLocation{
+				{FullyQualifiedName: v.Path},
+			}
+		}
+
 		sarif.Runs[0].Results = append(sarif.Runs[0].Results, result)
 	}
 
@@ -225,10 +253,16 @@ func getSeverityLevel(severity int) string {
 
 // toFileURI converts a file path to a URI
 func toFileURI(path string) string {
-	// Handle special URIs (e.g., s3://, slack://, table://)
+	// Handle special URIs (e.g., s3://, slack://, table://, routine://, mongodb://, redis://)
 	if strings.HasPrefix(path, "s3://") || 
 	   strings.HasPrefix(path, "slack://") || 
-	   strings.HasPrefix(path, "table://") {
+	   strings.HasPrefix(path, "table://") ||
+	   strings.HasPrefix(path, "routine://") ||
+	   strings.HasPrefix(path, "view://") ||
+	   strings.HasPrefix(path, "trigger://") ||
+	   strings.HasPrefix(path, "server://") ||
+	   strings.HasPrefix(path, "mongodb://") ||
+	   strings.HasPrefix(path, "redis://") {
 		return path
 	}
 
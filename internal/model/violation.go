@@ -5,8 +5,15 @@ type Violation struct {
 	Rule      Configuration // The rule that triggered this violation
 	Match     string       // The actual text that matched the rule
 	Location  string       // Where the violation was found (file path, URL, etc.)
+	Path      string       // Structural path within the location (e.g. notebook cell), if any
 	Context   string       // Surrounding context of the violation
 	LineNum   int         // Line number in the file (if applicable)
 	StartCol  int         // Starting column of the match (if applicable)
 	EndCol    int         // Ending column of the match (if applicable)
+	Commit     string       // Commit that introduced the match (git scans)
+	Author     string       // Author of that commit, as "Name <email>"
+	CommitDate string       // Author date of that commit (ISO 8601)
+	Blob         string     // Git blob containing the match (git object scans)
+	Reachability string     // How that blob is reachable: ref, stash, reflog or unreachable
+	Query        string     // SELECT statement returning the offending row (database scans)
 }
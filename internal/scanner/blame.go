@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// blameInfo is what git blame reports about the commit that introduced a line
+type blameInfo struct {
+	commit     string
+	author     string
+	authorMail string
+	authorTime int64
+	authorTZ   string
+}
+
+// AnnotateBlame attributes file violations to the commit that introduced
+// their line, using git blame. Violations outside a git repository, on lines
+// that are not committed yet, or without a file line number are left as is.
+func AnnotateBlame(ctx context.Context, violations []model.Violation) {
+	byFile := make(map[string][]int)
+	for i, v := range violations {
+		if v.LineNum <= 0 || v.Commit != "" {
+			continue
+		}
+		// Notebook lines are relative to their cell
+		if detectFileFormat(v.Location, nil) == FormatNotebook {
+			continue
+		}
+		byFile[v.Location] = append(byFile[v.Location], i)
+	}
+
+	for file, indexes := range byFile {
+		lines := make([]int, 0, len(indexes))
+		for _, i := range indexes {
+			lines = append(lines, violations[i].LineNum)
+		}
+
+		blame, err := blameLines(ctx, file, lines)
+		if err != nil {
+			continue
+		}
+
+		for _, i := range indexes {
+			info, ok := blame[violations[i].LineNum]
+			if !ok {
+				continue
+			}
+			violations[i].Commit = info.commit
+			violations[i].Author = fmt.Sprintf("%s %s", info.author, info.authorMail)
+			violations[i].CommitDate = blameDate(info)
+		}
+	}
+}
+
+// blameLines runs git blame on the given lines of a file and returns the
+// commit that introduced each of them
+func blameLines(ctx context.Context, file string, lines []int) (map[int]blameInfo, error) {
+	args := []string{"-C", filepath.Dir(file), "blame", "--porcelain"}
+	seen := make(map[int]bool)
+	for _, line := range lines {
+		if !seen[line] {
+			seen[line] = true
+			args = append(args, "-L", fmt.Sprintf("%d,%d", line, line))
+		}
+	}
+	args = append(args, "--", filepath.Base(file))
+
+	output, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseBlamePorcelain(output), nil
+}
+
+// parseBlamePorcelain parses git blame --porcelain output into the commit of
+// each final line. Commit details are only printed the first time a commit
+// appears, so they are tracked per commit.
+func parseBlamePorcelain(output []byte) map[int]blameInfo {
+	commits := make(map[string]*blameInfo)
+	lineCommits := make(map[int]string)
+
+	var current *blameInfo
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), streamChunkSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			// The content of the blamed line ends its entry
+			current = nil
+
+		case current == nil:
+			// <sha> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			finalLine, err := strconv.Atoi(fields[2])
+			if err != nil {
+				continue
+			}
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &blameInfo{commit: fields[0]}
+			}
+			current = commits[fields[0]]
+			lineCommits[finalLine] = fields[0]
+
+		case strings.HasPrefix(line, "author "):
+			current.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			current.authorMail = strings.TrimPrefix(line, "author-mail ")
+		case strings.HasPrefix(line, "author-time "):
+			current.authorTime, _ = strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+		case strings.HasPrefix(line, "author-tz "):
+			current.authorTZ = strings.TrimPrefix(line, "author-tz ")
+		}
+	}
+
+	result := make(map[int]blameInfo)
+	for line, sha := range lineCommits {
+		// Lines changed in the working tree are attributed to a zero commit
+		if isZeroSHA(sha) {
+			continue
+		}
+		result[line] = *commits[sha]
+	}
+	return result
+}
+
+// blameDate formats the author time of a blame entry in its own time zone
+func blameDate(info blameInfo) string {
+	t := time.Unix(info.authorTime, 0).UTC()
+	if tz, err := time.Parse("-0700", info.authorTZ); err == nil {
+		t = t.In(tz.Location())
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,228 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// scanCacheVersion is bumped whenever scanning logic changes in a way that
+// makes previously cached findings stale
+const scanCacheVersion = 2
+
+// scanCacheMaxAge is how long findings cached for a rule set are kept after
+// it was last used. Rule sets are not removed as soon as another one is used,
+// as several configurations or versions may share a cache directory.
+const scanCacheMaxAge = 30 * 24 * time.Hour
+
+// ScanCache stores the findings of each scanned file on disk, keyed by the
+// file's content hash, so unchanged files are not scanned again. Entries are
+// grouped by rule-set version, which changes whenever a rule changes, so
+// editing rules invalidates the cache automatically.
+type ScanCache struct {
+	dir     string
+	ruleset string
+	Hits    int
+	Misses  int
+}
+
+// cacheEntry is the on-disk representation of a cached file scan
+type cacheEntry struct {
+	Ruleset  string          `json:"ruleset"`
+	Findings []cachedFinding `json:"findings"`
+}
+
+// cachedFinding is a violation without the file path of its location, which
+// is filled in from the file being replayed. Findings within a file, such as
+// the rows of SQLite databases and SQL dumps, keep the rest of their location.
+// Rules are referenced by their index in the rule set.
+type cachedFinding struct {
+	Rule     int    `json:"rule"`
+	Match    string `json:"match"`
+	Within   string `json:"within,omitempty"` // Location following the file path
+	Path     string `json:"path,omitempty"`
+	LineNum  int    `json:"line,omitempty"`
+	StartCol int    `json:"startCol,omitempty"`
+	EndCol   int    `json:"endCol,omitempty"`
+	Context  string `json:"context,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+// DefaultCacheDir returns ~/.blacklight/cache
+func DefaultCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %v", err)
+	}
+	return filepath.Join(home, ".blacklight", "cache"), nil
+}
+
+// NewScanCache opens the cache in dir for the given rules, removing entries
+// cached for rule sets that were not used within scanCacheMaxAge
+func NewScanCache(dir string, patterns []model.Configuration) (*ScanCache, error) {
+	ruleset := rulesetVersion(patterns)
+
+	if err := os.MkdirAll(filepath.Join(dir, ruleset), 0755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %v", err)
+	}
+
+	// The modification time of a rule set's directory records when it was
+	// last used
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(dir, ruleset), now, now); err != nil {
+		return nil, fmt.Errorf("error updating cache directory: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache directory: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == ruleset {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && now.Sub(info.ModTime()) > scanCacheMaxAge {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return &ScanCache{dir: dir, ruleset: ruleset}, nil
+}
+
+// rulesetVersion returns a hash identifying the rules and scanner version
+func rulesetVersion(patterns []model.Configuration) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", scanCacheVersion)
+	for _, p := range patterns {
+		fmt.Fprintf(h, "%q %q %q %d %q %t\n", p.ID, p.Name, p.Regex, p.Severity, p.Type, p.Disabled)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// fileKey hashes the content of a file. The extension is part of the key
+// because it decides how content is parsed.
+func fileKey(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", strings.ToLower(filepath.Ext(filePath)))
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *ScanCache) entryPath(key string) string {
+	return filepath.Join(c.dir, c.ruleset, key[:2], key+".json")
+}
+
+// load returns the cached findings for key
+func (c *ScanCache) load(key string, patterns []model.Configuration, location string) ([]model.Violation, bool) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Ruleset != c.ruleset {
+		return nil, false
+	}
+
+	violations := make([]model.Violation, 0, len(entry.Findings))
+	for _, f := range entry.Findings {
+		if f.Rule < 0 || f.Rule >= len(patterns) {
+			return nil, false
+		}
+		violations = append(violations, model.Violation{
+			Rule:     patterns[f.Rule],
+			Match:    f.Match,
+			Location: location + f.Within,
+			Path:     f.Path,
+			LineNum:  f.LineNum,
+			StartCol: f.StartCol,
+			EndCol:   f.EndCol,
+			Context:  f.Context,
+			Query:    f.Query,
+		})
+	}
+	return violations, true
+}
+
+// store caches the findings for key, found in the file at location
+func (c *ScanCache) store(key string, patterns []model.Configuration, location string, violations []model.Violation) error {
+	entry := cacheEntry{Ruleset: c.ruleset, Findings: make([]cachedFinding, 0, len(violations))}
+	for _, v := range violations {
+		rule := ruleIndex(patterns, v.Rule)
+		if rule == -1 {
+			return fmt.Errorf("rule %s is not in the rule set", v.Rule.Name)
+		}
+		if !strings.HasPrefix(v.Location, location) {
+			return fmt.Errorf("finding location %s is outside %s", v.Location, location)
+		}
+		entry.Findings = append(entry.Findings, cachedFinding{
+			Rule:     rule,
+			Match:    v.Match,
+			Within:   strings.TrimPrefix(v.Location, location),
+			Path:     v.Path,
+			LineNum:  v.LineNum,
+			StartCol: v.StartCol,
+			EndCol:   v.EndCol,
+			Context:  v.Context,
+			Query:    v.Query,
+		})
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ruleIndex returns the index of rule in patterns, or -1
+func ruleIndex(patterns []model.Configuration, rule model.Configuration) int {
+	for i, p := range patterns {
+		if p.ID == rule.ID && p.Name == rule.Name && p.Regex == rule.Regex {
+			return i
+		}
+	}
+	return -1
+}
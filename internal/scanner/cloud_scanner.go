@@ -189,14 +189,15 @@ func (s *CloudScanner) scanContent(reader io.Reader, fileName, location string)
 	var violations []model.Violation
 
 	// Extract text content based on file format
-	lines, err := extractTextFromFile(fileName, reader)
+	segments, err := extractTextFromFile(fileName, reader)
 	if err != nil {
 		fmt.Printf("Warning: Error processing file %s: %v\n", fileName, err)
 		return violations
 	}
 
-	// Process each extracted line
-	for lineNum, line := range lines {
+	// Process each extracted segment
+	for _, segment := range segments {
+		line := segment.Text
 		for _, pattern := range s.patterns {
 			if pattern.CompiledRegex == nil {
 				fmt.Printf("Warning: Skipping rule %s - regex not compiled\n", pattern.Name)
@@ -209,7 +210,8 @@ func (s *CloudScanner) scanContent(reader io.Reader, fileName, location string)
 					Rule:     pattern,
 					Match:    line,
 					Location: location,
-					LineNum:  lineNum + 1,
+					Path:     segment.Path,
+					LineNum:  segment.LineNum,
 					Context:  fmt.Sprintf("File: %s - %s", fileName, line),
 				}
 				violations = append(violations, violation)
@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dbCheckpoints records, per database and table, the key of the last row an
+// incremental scan has read, so the next scan starts after it. Tables scanned
+// concurrently share it.
+type dbCheckpoints struct {
+	path      string
+	mu        sync.Mutex
+	Databases map[string]map[string]dbCheckpoint `json:"databases"`
+}
+
+// dbCheckpoint is the last row read from a table, identified by the values of
+// the columns the table is read in order of
+type dbCheckpoint struct {
+	Columns []string `json:"columns"`
+	Values  []string `json:"values"`
+}
+
+// DefaultDBCheckpointFile returns ~/.blacklight/db-checkpoints.json
+func DefaultDBCheckpointFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %v", err)
+	}
+	return filepath.Join(home, ".blacklight", "db-checkpoints.json"), nil
+}
+
+// loadDBCheckpoints reads the checkpoint file at path, which may not exist yet
+func loadDBCheckpoints(path string) (*dbCheckpoints, error) {
+	checkpoints := &dbCheckpoints{path: path, Databases: make(map[string]map[string]dbCheckpoint)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoints: %v", err)
+	}
+	if err := json.Unmarshal(data, checkpoints); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoints %s: %v", path, err)
+	}
+	if checkpoints.Databases == nil {
+		checkpoints.Databases = make(map[string]map[string]dbCheckpoint)
+	}
+	return checkpoints, nil
+}
+
+// get returns the checkpoint of a table, or nil if there is none or the table
+// was last read in order of different columns
+func (c *dbCheckpoints) get(database, table string, columns []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint, ok := c.Databases[database][table]
+	if !ok || strings.Join(checkpoint.Columns, "\x00") != strings.Join(columns, "\x00") {
+		return nil
+	}
+	return checkpoint.Values
+}
+
+// set records the checkpoint of a table and saves the file
+func (c *dbCheckpoints) set(database, table string, columns, values []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Databases[database] == nil {
+		c.Databases[database] = make(map[string]dbCheckpoint)
+	}
+	c.Databases[database][table] = dbCheckpoint{Columns: columns, Values: values}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
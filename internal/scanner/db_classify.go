@@ -0,0 +1,322 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Column classes of a sensitive data map, in order of precedence
+const (
+	ClassPCI        = "pci"        // Payment card data
+	ClassCredential = "credential" // Passwords, tokens and keys
+	ClassPII        = "pii"        // Personal data
+	ClassNone       = "none"
+)
+
+// sensitiveClasses are the classes a column can be labeled with other than
+// none, in order of precedence
+var sensitiveClasses = []string{ClassPCI, ClassCredential, ClassPII}
+
+// How much column names and sampled values weigh in a classification; a name
+// match and every sampled value matching make a confidence of 1
+const (
+	classifyNameWeight  = 0.4
+	classifyValueWeight = 0.6
+)
+
+// pciColumnPatterns match names of columns that likely hold card data
+var pciColumnPatterns = compileColumnPatterns(
+	"(?i)card[_-]?num",
+	"(?i)cc[_-]?num",
+	"(?i)credit[_-]?card",
+	"(?i)card[_-]?holder",
+	"(?i)cvv",
+	"(?i)cvc",
+	"(?i)ccv",
+	"(?i)(^|_)cid($|_)",
+	"(?i)card[_-]?pin",
+	"(?i)card[_-]?expiry",
+	"(?i)card[_-]?exp",
+	"(?i)merchant[_-]?id",
+)
+
+// credentialColumnPatterns match names of columns that likely hold secrets
+var credentialColumnPatterns = compileColumnPatterns(
+	"(?i)passw(or)?d",
+	"(?i)^pwd$|_pwd$",
+	"(?i)secret",
+	"(?i)token",
+	"(?i)api[_-]?key",
+	"(?i)access[_-]?key",
+	"(?i)private[_-]?key",
+	"(?i)credential",
+	"(?i)salt$",
+)
+
+// piiColumnPatterns match names of columns that likely hold personal data
+var piiColumnPatterns = compileColumnPatterns(
+	"(?i)e[_-]?mail",
+	"(?i)phone|mobile",
+	"(?i)ssn|social[_-]?security",
+	"(?i)(first|last|full|given|family|sur)[_-]?name",
+	"(?i)birth|dob$",
+	"(?i)address|street|postal[_-]?code|zip[_-]?code",
+	"(?i)passport",
+	"(?i)national[_-]?id|tax[_-]?id",
+	"(?i)ip[_-]?addr",
+)
+
+// piiValuePatterns match personal data in values; rules only detect secrets
+// and card data
+var piiValuePatterns = compileColumnPatterns(
+	`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`,
+	`^\d{3}-\d{2}-\d{4}$`,
+)
+
+func compileColumnPatterns(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile(pattern)
+	}
+	return compiled
+}
+
+// matchColumnName reports whether a column name matches any of the patterns
+func matchColumnName(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassificationReport is a sensitive data map of a database, labeling every
+// column with the kind of data it holds
+type ClassificationReport struct {
+	Database    string                 `json:"database" yaml:"database"`
+	GeneratedAt string                 `json:"generatedAt" yaml:"generatedAt"`
+	SampleSize  int                    `json:"sampleSize" yaml:"sampleSize"`
+	Columns     []ColumnClassification `json:"columns" yaml:"columns"`
+}
+
+// ColumnClassification labels a column as pci, pii, credential or none.
+// Confidence combines whether the column name suggests the class with the
+// share of sampled values matching it.
+type ColumnClassification struct {
+	Schema     string   `json:"schema" yaml:"schema"`
+	Table      string   `json:"table" yaml:"table"`
+	Column     string   `json:"column" yaml:"column"`
+	DataType   string   `json:"dataType" yaml:"dataType"`
+	Class      string   `json:"class" yaml:"class"`
+	Confidence float64  `json:"confidence" yaml:"confidence"`
+	NameMatch  bool     `json:"nameMatch" yaml:"nameMatch"`             // The column name suggests the class
+	Sampled    int      `json:"sampled" yaml:"sampled"`                 // Non-empty values sampled
+	Matched    int      `json:"matched" yaml:"matched"`                 // Sampled values matching the class
+	MatchRate  float64  `json:"matchRate" yaml:"matchRate"`             // Matched divided by sampled
+	Rules      []string `json:"rules,omitempty" yaml:"rules,omitempty"` // Rules that matched sampled values
+}
+
+// Classify labels every column of the selected tables, sampling
+// config.SampleSize rows of each table in read-only transactions
+func (s *DBScanner) Classify(ctx context.Context, dbURL string) (*ClassificationReport, error) {
+	dialect, dataSourceName, err := dialectForURL(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(dbURL)
+	if dialect.Name() == "sqlite" {
+		dataSourceName = sqliteDSN(sqliteURLPath(u))
+	}
+
+	db, err := openDB(ctx, dialect, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	report, err := s.classifyDB(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	report.Database = u.Redacted()
+	return report, nil
+}
+
+func (s *DBScanner) classifyDB(ctx context.Context, db *sql.DB, dialect Dialect) (*ClassificationReport, error) {
+	tables, err := s.selectTables(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ClassificationReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		SampleSize:  s.config.SampleSize,
+		Columns:     []ColumnClassification{},
+	}
+
+	results := make([][]ColumnClassification, len(tables))
+	errs := s.forEachTable(ctx, db, tables, func(q querier, i int) error {
+		var err error
+		results[i], err = s.classifyTable(ctx, q, dialect, tables[i])
+		return err
+	})
+	for i, table := range tables {
+		if errs[i] != nil {
+			// Reports are often written to stdout, keep them parseable
+			fmt.Fprintf(os.Stderr, "Error classifying table %s: %v\n", table, errs[i])
+		}
+		report.Columns = append(report.Columns, results[i]...)
+	}
+	return report, ctx.Err()
+}
+
+// classifyTable samples the rows of a table and classifies each column
+func (s *DBScanner) classifyTable(ctx context.Context, q querier, dialect Dialect, table Table) ([]ColumnClassification, error) {
+	columns, _, err := s.describeTable(ctx, q, dialect, table)
+	if err != nil || len(columns) == 0 {
+		return nil, err
+	}
+
+	ctx, cancel := s.config.queryContext(ctx)
+	defer cancel()
+
+	scan := newTableScan(dialect, table, nil, columns, nil)
+	query := fmt.Sprintf("SELECT %s FROM %s", scan.selectList(), quoteTable(dialect, table))
+	if s.config.SampleSize > 0 {
+		query, err = dialect.SampleQuery(ctx, q, table, scan.selectList(), s.config.SampleSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build sample query: %v", err)
+		}
+	}
+
+	// Count, per column, the sampled values and the values matching each class
+	sampled := make([]int, len(columns))
+	matched := make([]map[string]int, len(columns))
+	rules := make([]map[string]bool, len(columns))
+	for i := range columns {
+		matched[i] = make(map[string]int)
+		rules[i] = make(map[string]bool)
+	}
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if !value.Valid || value.String == "" {
+				continue
+			}
+			sampled[i]++
+			for class, rule := range s.classifyValue(value.String) {
+				matched[i][class]++
+				if rule != "" {
+					rules[i][rule] = true
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]ColumnClassification, len(columns))
+	for i, column := range columns {
+		result[i] = classifyColumn(column.Name, sampled[i], matched[i])
+		result[i].Schema = table.Schema
+		result[i].Table = table.Name
+		result[i].DataType = column.DataType
+		for rule := range rules[i] {
+			result[i].Rules = append(result[i].Rules, rule)
+		}
+		sort.Strings(result[i].Rules)
+	}
+	return result, nil
+}
+
+// classifyValue returns the classes a value matches, with the name of the
+// first rule matching each, or "" for built-in personal data patterns
+func (s *DBScanner) classifyValue(value string) map[string]string {
+	classes := make(map[string]string)
+	for _, pattern := range s.patterns {
+		if pattern.CompiledRegex == nil {
+			continue
+		}
+		class := ClassCredential
+		if strings.EqualFold(pattern.Type, ClassPCI) || strings.EqualFold(pattern.Type, ClassPII) {
+			class = strings.ToLower(pattern.Type)
+		}
+		if _, ok := classes[class]; ok {
+			continue
+		}
+		if pattern.CompiledRegex.MatchString(value) {
+			classes[class] = pattern.Name
+		}
+	}
+	if _, ok := classes[ClassPII]; !ok && matchColumnName(piiValuePatterns, strings.TrimSpace(value)) {
+		classes[ClassPII] = ""
+	}
+	return classes
+}
+
+// classifyColumn picks the class with the highest score, a weighted sum of
+// the column name suggesting it and the share of sampled values matching it.
+// Columns matching no class are labeled none, with less confidence the fewer
+// values were sampled.
+func classifyColumn(name string, sampled int, matched map[string]int) ColumnClassification {
+	nameClasses := map[string]bool{
+		ClassPCI:        matchColumnName(pciColumnPatterns, name),
+		ClassCredential: matchColumnName(credentialColumnPatterns, name),
+		ClassPII:        matchColumnName(piiColumnPatterns, name),
+	}
+
+	result := ColumnClassification{Column: name, Class: ClassNone, Sampled: sampled}
+	best := 0.0
+	for _, class := range sensitiveClasses {
+		rate := 0.0
+		if sampled > 0 {
+			rate = float64(matched[class]) / float64(sampled)
+		}
+		score := classifyValueWeight * rate
+		if nameClasses[class] {
+			score += classifyNameWeight
+		}
+		if score > best {
+			best = score
+			result.Class = class
+			result.NameMatch = nameClasses[class]
+			result.Matched = matched[class]
+			result.MatchRate = roundConfidence(rate)
+		}
+	}
+
+	if result.Class == ClassNone {
+		best = 1 - classifyValueWeight/float64(sampled+1)
+	}
+	result.Confidence = roundConfidence(best)
+	return result
+}
+
+// roundConfidence rounds a ratio to two decimals for reports
+func roundConfidence(value float64) float64 {
+	return float64(int(value*100+0.5)) / 100
+}
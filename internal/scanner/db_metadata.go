@@ -0,0 +1,212 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// Kinds of catalog objects scanned with DBConfig.Metadata
+const (
+	metadataRoutine = "routine" // Function and stored procedure bodies
+	metadataView    = "view"    // View definitions
+	metadataTrigger = "trigger" // Trigger bodies
+	metadataServer  = "server"  // Foreign server and user mapping options
+	metadataDefault = "default" // Column defaults
+	metadataComment = "comment" // Table and column comments
+)
+
+// metadataQuery lists catalog objects of one kind. It selects the schema,
+// the object name, a detail (the column of defaults and comments, the user of
+// user mappings) and the text to scan.
+type metadataQuery struct {
+	kind  string
+	query string
+}
+
+// postgresUserSchema is a condition limiting a schema name column to user
+// schemas, as listed by Tables
+func postgresUserSchema(column string) string {
+	return fmt.Sprintf(`%[1]s NOT IN ('pg_catalog', 'information_schema', 'crdb_internal', 'pg_extension') AND %[1]s NOT LIKE 'pg\_toast%%'`, column)
+}
+
+func (postgresDialect) MetadataQueries() []metadataQuery {
+	return []metadataQuery{
+		{metadataRoutine, `
+			SELECT n.nspname, p.proname, '', p.prosrc
+			FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE ` + postgresUserSchema("n.nspname")},
+		{metadataView, `
+			SELECT schemaname, viewname, '', definition FROM pg_views WHERE ` + postgresUserSchema("schemaname") + `
+			UNION ALL
+			SELECT schemaname, matviewname, '', definition FROM pg_matviews WHERE ` + postgresUserSchema("schemaname")},
+		{metadataServer, `
+			SELECT '', srvname, '', array_to_string(srvoptions, E'\n')
+			FROM pg_foreign_server WHERE srvoptions IS NOT NULL`},
+		{metadataServer, `
+			SELECT '', srvname, usename, array_to_string(umoptions, E'\n')
+			FROM pg_user_mappings WHERE umoptions IS NOT NULL`},
+		{metadataDefault, `
+			SELECT table_schema, table_name, column_name, column_default
+			FROM information_schema.columns
+			WHERE column_default IS NOT NULL AND ` + postgresUserSchema("table_schema")},
+		{metadataComment, `
+			SELECT n.nspname, c.relname, COALESCE(a.attname, ''), d.description
+			FROM pg_description d
+			JOIN pg_class c ON c.oid = d.objoid AND d.classoid = 'pg_class'::regclass
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = d.objsubid AND d.objsubid > 0
+			WHERE ` + postgresUserSchema("n.nspname")},
+	}
+}
+
+// mysqlUserSchemas filters schema names to user databases
+const mysqlUserSchemas = `NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')`
+
+func (mysqlDialect) MetadataQueries() []metadataQuery {
+	return []metadataQuery{
+		{metadataRoutine, `
+			SELECT routine_schema, routine_name, '', routine_definition
+			FROM information_schema.routines
+			WHERE routine_definition IS NOT NULL AND routine_schema ` + mysqlUserSchemas},
+		{metadataView, `
+			SELECT table_schema, table_name, '', view_definition
+			FROM information_schema.views WHERE table_schema ` + mysqlUserSchemas},
+		{metadataTrigger, `
+			SELECT trigger_schema, trigger_name, '', action_statement
+			FROM information_schema.triggers WHERE trigger_schema ` + mysqlUserSchemas},
+		{metadataDefault, `
+			SELECT table_schema, table_name, column_name, column_default
+			FROM information_schema.columns
+			WHERE column_default IS NOT NULL AND table_schema ` + mysqlUserSchemas},
+		{metadataComment, `
+			SELECT table_schema, table_name, column_name, column_comment
+			FROM information_schema.columns
+			WHERE column_comment <> '' AND table_schema ` + mysqlUserSchemas + `
+			UNION ALL
+			SELECT table_schema, table_name, '', table_comment
+			FROM information_schema.tables
+			WHERE table_comment <> '' AND table_schema ` + mysqlUserSchemas},
+	}
+}
+
+// MetadataQueries lists views, triggers and column defaults; SQLite has no
+// routines, comments or foreign servers
+func (sqliteDialect) MetadataQueries() []metadataQuery {
+	return []metadataQuery{
+		{metadataView, `SELECT 'main', name, '', sql FROM sqlite_master WHERE type = 'view'`},
+		{metadataTrigger, `SELECT 'main', name, '', sql FROM sqlite_master WHERE type = 'trigger'`},
+		{metadataDefault, `
+			SELECT 'main', m.name, p.name, p.dflt_value
+			FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND p.dflt_value IS NOT NULL`},
+	}
+}
+
+// scanMetadata scans the definitions of catalog objects in the selected
+// schemas. Catalogs the user may not read are reported and skipped; each is
+// read in its own transaction, as a failed query aborts a transaction.
+func (s *DBScanner) scanMetadata(ctx context.Context, db *sql.DB, dialect Dialect) []model.Violation {
+	var schemas []string
+	err := s.readOnly(ctx, db, func(q querier) error {
+		queryCtx, cancel := s.config.queryContext(ctx)
+		defer cancel()
+		var err error
+		schemas, err = s.config.selectSchemas(queryCtx, q, dialect)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("Error scanning metadata: %v\n", err)
+		return nil
+	}
+
+	var violations []model.Violation
+	for _, query := range dialect.MetadataQueries() {
+		var found []model.Violation
+		err := s.readOnly(ctx, db, func(q querier) error {
+			var err error
+			found, err = s.scanMetadataQuery(ctx, q, query, schemas)
+			return err
+		})
+		if err != nil {
+			fmt.Printf("Warning: skipping %s metadata: %v\n", query.kind, err)
+		}
+		violations = append(violations, found...)
+	}
+	return violations
+}
+
+func (s *DBScanner) scanMetadataQuery(ctx context.Context, q querier, query metadataQuery, schemas []string) ([]model.Violation, error) {
+	ctx, cancel := s.config.queryContext(ctx)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []model.Violation
+	for rows.Next() {
+		var schema, name, detail, text sql.NullString
+		if err := rows.Scan(&schema, &name, &detail, &text); err != nil {
+			return violations, err
+		}
+		if text.String == "" {
+			continue
+		}
+
+		// Servers belong to no schema; defaults and comments belong to tables
+		table := Table{Schema: schema.String, Name: name.String}
+		if schema.String != "" && len(schemas) > 0 && !matchGlobs(schemas, schema.String) {
+			continue
+		}
+		if query.kind == metadataDefault || query.kind == metadataComment {
+			if len(s.config.IncludeTables) > 0 && !matchTableGlobs(s.config.IncludeTables, table) {
+				continue
+			}
+			if matchTableGlobs(s.config.ExcludeTables, table) {
+				continue
+			}
+		}
+
+		location, path := s.metadataLocation(query.kind, table, detail.String)
+		var segments []Segment
+		for i, line := range strings.Split(text.String, "\n") {
+			segments = append(segments, Segment{Text: line, Path: path, LineNum: i + 1, Col: 1})
+		}
+		matchSegments(s.patterns, location, segments, func(v model.Violation) {
+			violations = append(violations, v)
+		})
+	}
+	return violations, rows.Err()
+}
+
+// metadataLocation locates a catalog object: routine://schema/name,
+// view://schema/name, trigger://schema/name, server://name with the options
+// or user mapping as path, and the table or column of defaults and comments
+// with the kind as path
+func (s *DBScanner) metadataLocation(kind string, object Table, detail string) (string, string) {
+	switch kind {
+	case metadataServer:
+		if detail != "" {
+			return "server://" + object.Name, "user mapping " + detail
+		}
+		return "server://" + object.Name, "options"
+
+	case metadataDefault, metadataComment:
+		if s.locate != nil {
+			return s.locate(object, detail, nil, nil), kind
+		}
+		location := fmt.Sprintf("table://%s/%s", s.name, object)
+		if detail != "" {
+			location += "/" + detail
+		}
+		return location, kind
+	}
+
+	return fmt.Sprintf("%s://%s/%s", kind, object.Schema, object.Name), ""
+}
@@ -0,0 +1,261 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// lsn is a position in the PostgreSQL write-ahead log
+type lsn uint64
+
+// String formats an LSN as PostgreSQL does, e.g. 16/B374D848
+func (l lsn) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+func parseLSN(s string) (lsn, error) {
+	var high, low uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &high, &low); err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %v", s, err)
+	}
+	return lsn(uint64(high)<<32 | uint64(low)), nil
+}
+
+// postgresEpoch is the origin of timestamps in the replication protocol
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// standbyStatusUpdate reports the WAL position written, flushed and applied,
+// so the server can release WAL up to it
+func standbyStatusUpdate(position lsn, now time.Time) []byte {
+	data := make([]byte, 1+8+8+8+8+1)
+	data[0] = 'r'
+	binary.BigEndian.PutUint64(data[1:], uint64(position))
+	binary.BigEndian.PutUint64(data[9:], uint64(position))
+	binary.BigEndian.PutUint64(data[17:], uint64(position))
+	binary.BigEndian.PutUint64(data[25:], uint64(now.Sub(postgresEpoch).Microseconds()))
+	return data
+}
+
+// Type OIDs of columns scanned as other than text
+var pgoutputTypes = map[uint32]string{
+	17:   "bytea",
+	114:  "json",
+	3802: "jsonb",
+}
+
+// relation is a table as described by a pgoutput Relation message
+type relation struct {
+	table   Table
+	columns []Column
+	keys    []string
+
+	scan     *tableScan
+	index    []int // Position of the value of each scanned column
+	keyIndex []int // Position of the value of each replica identity column
+}
+
+// changeScanner decodes pgoutput messages and scans the rows inserted and
+// updated by each transaction. Rows of tables outside the schema and table
+// filters, and excluded columns, are not scanned.
+type changeScanner struct {
+	s         *DBScanner
+	relations map[uint32]*relation
+	report    func(model.Violation)
+
+	inTransaction bool
+}
+
+func newChangeScanner(s *DBScanner, report func(model.Violation)) *changeScanner {
+	return &changeScanner{s: s, relations: make(map[uint32]*relation), report: report}
+}
+
+// errShortMessage reports a pgoutput message cut short
+var errShortMessage = errors.New("pgoutput message too short")
+
+// messageReader reads the fields of a pgoutput message
+type messageReader struct {
+	data []byte
+	err  error
+}
+
+func (r *messageReader) take(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	field := r.data[:n]
+	r.data = r.data[n:]
+	return field
+}
+
+func (r *messageReader) byte() byte {
+	if field := r.take(1); field != nil {
+		return field[0]
+	}
+	return 0
+}
+
+func (r *messageReader) uint16() uint16 {
+	if field := r.take(2); field != nil {
+		return binary.BigEndian.Uint16(field)
+	}
+	return 0
+}
+
+func (r *messageReader) uint32() uint32 {
+	if field := r.take(4); field != nil {
+		return binary.BigEndian.Uint32(field)
+	}
+	return 0
+}
+
+func (r *messageReader) uint64() uint64 {
+	if field := r.take(8); field != nil {
+		return binary.BigEndian.Uint64(field)
+	}
+	return 0
+}
+
+func (r *messageReader) string() string {
+	end := bytes.IndexByte(r.data, 0)
+	if r.err != nil || end == -1 {
+		r.err = errShortMessage
+		return ""
+	}
+	s := string(r.data[:end])
+	r.data = r.data[end+1:]
+	return s
+}
+
+// tuple reads tuple data, with nil for NULL and unchanged TOAST values
+func (r *messageReader) tuple() [][]byte {
+	values := make([][]byte, r.uint16())
+	for i := range values {
+		switch r.byte() {
+		case 't', 'b':
+			values[i] = r.take(int(r.uint32()))
+		}
+	}
+	return values
+}
+
+// handle decodes one pgoutput message. It returns the end of the transaction
+// once its commit is read, after every row of the transaction was scanned.
+func (c *changeScanner) handle(message []byte) (lsn, bool, error) {
+	r := &messageReader{data: message}
+	switch r.byte() {
+	case 'B': // Begin
+		c.inTransaction = true
+
+	case 'C': // Commit
+		r.byte()   // Flags
+		r.uint64() // Commit LSN
+		end := lsn(r.uint64())
+		if r.err != nil {
+			return 0, false, r.err
+		}
+		c.inTransaction = false
+		return end, true, nil
+
+	case 'R': // Relation
+		id := r.uint32()
+		rel := &relation{table: Table{Schema: r.string(), Name: r.string()}}
+		r.byte() // Replica identity setting
+		for i, n := 0, int(r.uint16()); i < n && r.err == nil; i++ {
+			flags := r.byte()
+			column := Column{Name: r.string()}
+			column.DataType = pgoutputTypes[r.uint32()]
+			r.uint32() // Type modifier
+			rel.columns = append(rel.columns, column)
+			if flags&1 != 0 {
+				rel.keys = append(rel.keys, column.Name)
+			}
+		}
+		if r.err != nil {
+			return 0, false, r.err
+		}
+		c.relations[id] = c.prepare(rel)
+
+	case 'I': // Insert
+		rel := c.relations[r.uint32()]
+		r.byte() // 'N'
+		values := r.tuple()
+		if r.err != nil {
+			return 0, false, r.err
+		}
+		c.scanRow(rel, values)
+
+	case 'U': // Update
+		rel := c.relations[r.uint32()]
+		if kind := r.byte(); kind == 'K' || kind == 'O' {
+			// The old key or row precedes the new row
+			r.tuple()
+			r.byte() // 'N'
+		}
+		values := r.tuple()
+		if r.err != nil {
+			return 0, false, r.err
+		}
+		c.scanRow(rel, values)
+	}
+
+	// Deletes, truncates, types and origins hold nothing new to scan
+	return 0, false, r.err
+}
+
+// prepare maps the columns of a relation to the scanned columns, or drops the
+// relation when its table is filtered out
+func (c *changeScanner) prepare(rel *relation) *relation {
+	config := c.s.config
+	if !config.selectTable(config.Schemas, rel.table) {
+		return nil
+	}
+
+	var columns []Column
+	for i, column := range rel.columns {
+		if !config.excludeColumn(rel.table, column.Name) {
+			columns = append(columns, column)
+			rel.index = append(rel.index, i)
+		}
+	}
+	for _, key := range rel.keys {
+		for i, column := range rel.columns {
+			if column.Name == key {
+				rel.keyIndex = append(rel.keyIndex, i)
+			}
+		}
+	}
+	rel.scan = newTableScan(postgresDialect{}, rel.table, rel.keys, columns, nil)
+	return rel
+}
+
+// scanRow scans the values of an inserted or updated row
+func (c *changeScanner) scanRow(rel *relation, values [][]byte) {
+	if rel == nil {
+		return
+	}
+
+	key := make([]string, len(rel.keyIndex))
+	for i, index := range rel.keyIndex {
+		if index < len(values) {
+			key[i] = string(values[index])
+		}
+	}
+	for i, column := range rel.scan.columns {
+		if rel.index[i] >= len(values) || len(values[rel.index[i]]) == 0 {
+			continue
+		}
+		value := values[rel.index[i]]
+		if kindOfColumn(column) == columnBinary {
+			value = decodeByteaHex(value)
+		}
+		for _, v := range c.s.scanValue(rel.scan, column, key, value) {
+			c.report(v)
+		}
+	}
+}
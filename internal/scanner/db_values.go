@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// DefaultDBMaxValueSize bounds how much of a binary value, including what is
+// decompressed or unpacked from it, is scanned
+const DefaultDBMaxValueSize = 16 << 20 // 16MB
+
+// maxUnpackDepth bounds how deeply compressed values and archives nested in
+// each other are unpacked
+const maxUnpackDepth = 4
+
+// Column kinds decide how the values of a column are scanned
+type columnKind int
+
+const (
+	columnText   columnKind = iota
+	columnJSON              // Scanned leaf by leaf through the JSON extractor
+	columnBinary            // Unpacked, then scanned by format
+)
+
+// kindOfColumn returns the kind of a column from its catalog data type
+func kindOfColumn(column Column) columnKind {
+	dataType := strings.ToLower(column.DataType)
+	switch {
+	case dataType == "json" || dataType == "jsonb":
+		return columnJSON
+	case dataType == "bytea" || strings.Contains(dataType, "blob") || strings.HasSuffix(dataType, "binary"):
+		return columnBinary
+	default:
+		return columnText
+	}
+}
+
+// scanValue checks a column value according to the column type: JSON
+// documents leaf by leaf, binary values after unpacking, anything else as text
+func (s *DBScanner) scanValue(scan *tableScan, column Column, key []string, value []byte) []model.Violation {
+	switch kindOfColumn(column) {
+	case columnJSON:
+		var document interface{}
+		if err := json.Unmarshal(value, &document); err != nil {
+			break
+		}
+		var leaves []pathValue
+		extractPathValues(&leaves, "", document)
+
+		var violations []model.Violation
+		for _, leaf := range leaves {
+			if v, ok := s.matchValue(scan, column.Name, key, leaf.Value); ok {
+				v.Path = leaf.Path
+				violations = append(violations, v)
+			}
+		}
+		return violations
+
+	case columnBinary:
+		return s.scanBinary(scan, column, key, value)
+	}
+
+	if v, ok := s.matchValue(scan, column.Name, key, string(value)); ok {
+		return []model.Violation{v}
+	}
+	return nil
+}
+
+// scanBinary unpacks a binary value and scans each part by its detected
+// format. Findings keep their line within the part, and the path of the part
+// within the value when it came from an archive.
+func (s *DBScanner) scanBinary(scan *tableScan, column Column, key []string, value []byte) []model.Violation {
+	location := s.rowLocation(scan, column.Name, key)
+	if s.locate != nil {
+		location = s.locate(scan.table, column.Name, scan.keys, key)
+	}
+	return s.scanUnpacked(location, value, func(match string) string {
+		return rowQuery(scan, column.Name, key, match)
+	})
+}
+
+// scanUnpacked unpacks a value and scans each part by its detected format,
+// locating findings at location. With EmitSelect, query returns the statement
+// reading the value back for a match.
+func (s *DBScanner) scanUnpacked(location string, value []byte, query func(match string) string) []model.Violation {
+	maxSize := s.config.MaxValueSize
+	if maxSize <= 0 {
+		maxSize = DefaultDBMaxValueSize
+	}
+
+	contentScanner := NewSecretScanner()
+	contentScanner.AddPattern(s.patterns...)
+
+	var violations []model.Violation
+	budget := maxSize
+	for _, part := range unpackBinary("", value, &budget, 0) {
+		for _, v := range contentScanner.ScanReader(bytes.NewReader(part.data), part.path) {
+			v.Location = location
+			switch {
+			case part.path != "" && v.Path != "":
+				v.Path = part.path + "!/" + v.Path
+			case part.path != "":
+				v.Path = part.path
+			}
+			if s.config.EmitSelect {
+				v.Query = query(v.Match)
+			}
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// binaryPart is content unpacked from a binary value, and the path of the
+// archive entry it was read from, if any
+type binaryPart struct {
+	path string
+	data []byte
+}
+
+// unpackBinary decompresses gzip and zlib data and lists the entries of zip
+// archives, recursively up to maxUnpackDepth. Anything else is returned as is.
+// Unpacked content is cut off once budget bytes have been unpacked in total,
+// so compression bombs cannot exhaust memory.
+func unpackBinary(path string, data []byte, budget *int64, depth int) []binaryPart {
+	if int64(len(data)) > *budget {
+		data = data[:*budget]
+	}
+	if depth >= maxUnpackDepth {
+		*budget -= int64(len(data))
+		return []binaryPart{{path: path, data: data}}
+	}
+
+	switch {
+	case isGzip(data):
+		if r, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			if unpacked, err := readLimited(r, *budget); err == nil {
+				return unpackBinary(path, unpacked, budget, depth+1)
+			}
+		}
+
+	case isZlib(data):
+		if r, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			if unpacked, err := readLimited(r, *budget); err == nil {
+				return unpackBinary(path, unpacked, budget, depth+1)
+			}
+		}
+
+	case isZip(data):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			break
+		}
+		var parts []binaryPart
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() || *budget <= 0 {
+				continue
+			}
+			r, err := file.Open()
+			if err != nil {
+				continue
+			}
+			unpacked, err := readLimited(r, *budget)
+			r.Close()
+			if err != nil {
+				continue
+			}
+
+			name := file.Name
+			if path != "" {
+				name = path + "!/" + file.Name
+			}
+			parts = append(parts, unpackBinary(name, unpacked, budget, depth+1)...)
+		}
+		return parts
+	}
+
+	*budget -= int64(len(data))
+	return []binaryPart{{path: path, data: data}}
+}
+
+// readLimited reads at most limit bytes from r. Content past the limit is
+// dropped rather than failing the read.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit))
+	if err == io.ErrUnexpectedEOF && len(data) > 0 {
+		// Truncated streams still yield what was decompressed
+		err = nil
+	}
+	return data, err
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isZlib checks the zlib header: deflate compression and a valid check value
+func isZlib(data []byte) bool {
+	return len(data) > 2 && data[0]&0x0f == 8 && data[0]>>4 <= 7 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06"))
+}
@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// Defaults for watching a database
+const (
+	DefaultDBWatchSlot           = "blacklight"
+	DefaultDBWatchPublication    = "blacklight"
+	DefaultDBWatchStatusInterval = 10 * time.Second
+)
+
+// DBWatchConfig configures Watch
+type DBWatchConfig struct {
+	Slot        string // Logical replication slot, created when missing
+	Publication string // Publication streamed, created for all tables when missing
+
+	// How often progress is confirmed to the server and recorded in the
+	// checkpoint file
+	StatusInterval time.Duration
+}
+
+// Watch streams the rows inserted and updated in a PostgreSQL database through
+// logical replication with pgoutput and reports the findings in each row as
+// changes arrive, until ctx is done.
+//
+// The replication slot keeps every change the watcher has not confirmed, and
+// a change is only confirmed once the transaction holding it was scanned, so
+// a restarted watcher resumes where the last one stopped. The confirmed
+// position is also recorded in the checkpoint file, which the watcher resumes
+// from when it is ahead of the slot. Changes made before the slot was first
+// created are not seen; scan the database once to cover them.
+func (s *DBScanner) Watch(ctx context.Context, dbURL string, watch DBWatchConfig, report func(model.Violation)) error {
+	dialect, dataSourceName, err := dialectForURL(dbURL)
+	if err != nil {
+		return err
+	}
+	if dialect.Name() != "postgres" {
+		return fmt.Errorf("watching needs PostgreSQL logical replication, %s is not supported", dialect.Name())
+	}
+	if watch.Slot == "" {
+		watch.Slot = DefaultDBWatchSlot
+	}
+	if watch.Publication == "" {
+		watch.Publication = DefaultDBWatchPublication
+	}
+	if watch.StatusInterval <= 0 {
+		watch.StatusInterval = DefaultDBWatchStatusInterval
+	}
+
+	u, _ := url.Parse(dbURL)
+	s.database = u.Redacted()
+	s.name = strings.Trim(u.Path, "/")
+	if s.name == "" {
+		s.name = u.Host
+	}
+
+	file := s.config.CheckpointFile
+	if file == "" {
+		if file, err = DefaultDBCheckpointFile(); err != nil {
+			return err
+		}
+	}
+	if s.checkpoints, err = loadDBCheckpoints(file); err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, dialect, dataSourceName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := preparePublication(ctx, db, watch.Publication); err != nil {
+		return err
+	}
+
+	// Replication commands need a replication connection
+	replicationURL := *u
+	query := replicationURL.Query()
+	query.Set("replication", "database")
+	replicationURL.RawQuery = query.Encode()
+	conn, err := pgconn.Connect(ctx, replicationURL.String())
+	if err != nil {
+		return fmt.Errorf("failed to open replication connection: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	if err := prepareSlot(ctx, db, conn, watch.Slot); err != nil {
+		return err
+	}
+
+	var start lsn
+	checkpoint := "slot " + watch.Slot
+	if values := s.checkpoints.get(s.database, checkpoint, []string{"lsn"}); len(values) == 1 {
+		if start, err = parseLSN(values[0]); err != nil {
+			return err
+		}
+	}
+
+	command := fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL %s (proto_version '1', publication_names %s)",
+		postgresDialect{}.QuoteIdent(watch.Slot), start, sqlString(postgresDialect{}, postgresDialect{}.QuoteIdent(watch.Publication)))
+	conn.Frontend().Send(&pgproto3.Query{String: command})
+	if err := conn.Frontend().Flush(); err != nil {
+		return fmt.Errorf("failed to start replication: %v", err)
+	}
+	if err := awaitCopyBoth(ctx, conn); err != nil {
+		return fmt.Errorf("failed to start replication: %v", err)
+	}
+
+	return s.stream(ctx, conn, newChangeScanner(s, report), start, watch, checkpoint)
+}
+
+// stream reads the replication stream, confirming the end of each scanned
+// transaction every status interval and when ctx is done
+func (s *DBScanner) stream(ctx context.Context, conn *pgconn.PgConn, changes *changeScanner, start lsn, watch DBWatchConfig, checkpoint string) error {
+	scanned := start   // End of the last transaction scanned
+	confirmed := start // Last position confirmed to the server
+	nextStatus := time.Now().Add(watch.StatusInterval)
+
+	confirm := func() error {
+		if scanned != confirmed {
+			// The checkpoint comes first: it may get ahead of the slot, never behind
+			if err := s.checkpoints.set(s.database, checkpoint, []string{"lsn"}, []string{scanned.String()}); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving checkpoint for slot %s: %v\n", watch.Slot, err)
+			}
+		}
+		conn.Frontend().Send(&pgproto3.CopyData{Data: standbyStatusUpdate(scanned, time.Now())})
+		if err := conn.Frontend().Flush(); err != nil {
+			return fmt.Errorf("failed to confirm replication progress: %v", err)
+		}
+		confirmed = scanned
+		nextStatus = time.Now().Add(watch.StatusInterval)
+		return nil
+	}
+
+	for {
+		if !time.Now().Before(nextStatus) {
+			if err := confirm(); err != nil {
+				return err
+			}
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		message, err := conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if ctx.Err() != nil {
+			// Confirm what was scanned so a restart does not scan it again
+			if err == nil || pgconn.Timeout(err) {
+				confirm()
+			}
+			return nil
+		}
+		if pgconn.Timeout(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("replication stream failed: %v", err)
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("replication stream failed: %v", pgconn.ErrorResponseToPgError(message))
+
+		case *pgproto3.CopyData:
+			if len(message.Data) == 0 {
+				continue
+			}
+			switch message.Data[0] {
+			case 'k': // Keepalive: WAL end, server time, reply requested
+				if len(message.Data) < 18 {
+					continue
+				}
+				// Between transactions, everything up to the WAL end was scanned
+				if !changes.inTransaction {
+					if end := lsn(binary.BigEndian.Uint64(message.Data[1:])); end > scanned {
+						scanned = end
+					}
+				}
+				if message.Data[17] == 1 {
+					nextStatus = time.Now()
+				}
+
+			case 'w': // WAL data: start, end, server time, then a pgoutput message
+				if len(message.Data) < 25 {
+					continue
+				}
+				end, committed, err := changes.handle(message.Data[25:])
+				if err != nil {
+					return fmt.Errorf("failed to decode change: %v", err)
+				}
+				if committed && end > scanned {
+					scanned = end
+				}
+			}
+		}
+	}
+}
+
+// awaitCopyBoth waits for the server to start streaming
+func awaitCopyBoth(ctx context.Context, conn *pgconn.PgConn) error {
+	for {
+		message, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		switch message := message.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(message)
+		}
+	}
+}
+
+// preparePublication creates the publication for all tables unless it exists
+func preparePublication(ctx context.Context, db *sql.DB, publication string) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1)", publication).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up publication: %v", err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "CREATE PUBLICATION "+postgresDialect{}.QuoteIdent(publication)+" FOR ALL TABLES"); err != nil {
+		return fmt.Errorf("failed to create publication %s: %v", publication, err)
+	}
+	fmt.Fprintf(os.Stderr, "Created publication %s for all tables\n", publication)
+	return nil
+}
+
+// prepareSlot creates the replication slot unless it exists. Existing slots
+// must use pgoutput.
+func prepareSlot(ctx context.Context, db *sql.DB, conn *pgconn.PgConn, slot string) error {
+	var plugin string
+	err := db.QueryRowContext(ctx, "SELECT plugin FROM pg_replication_slots WHERE slot_name = $1", slot).Scan(&plugin)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return fmt.Errorf("failed to look up replication slot: %v", err)
+	case plugin != "pgoutput":
+		return fmt.Errorf("replication slot %s uses %s, not pgoutput", slot, plugin)
+	default:
+		return nil
+	}
+
+	command := fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput", postgresDialect{}.QuoteIdent(slot))
+	if _, err := conn.Exec(ctx, command).ReadAll(); err != nil {
+		return fmt.Errorf("failed to create replication slot %s: %v", slot, err)
+	}
+	fmt.Fprintf(os.Stderr, "Created replication slot %s\n", slot)
+	return nil
+}
@@ -1,25 +1,98 @@
 package scanner
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"regexp"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/adaptive-scale/blacklight/internal/model"
 
-	_ "github.com/go-sql-driver/mysql" // MySQL driver
-	_ "github.com/lib/pq"              // PostgreSQL driver
+	_ "github.com/go-sql-driver/mysql" // MySQL and MariaDB driver
+	_ "github.com/lib/pq"              // PostgreSQL and CockroachDB driver
 )
 
+// Database scan modes
+const (
+	DBModeSample      = "sample"      // A random sample of rows from each table
+	DBModeFull        = "full"        // Every row, read in primary key order in batches
+	DBModeIncremental = "incremental" // Rows added or updated since the last incremental scan
+)
+
+// DefaultDBBatchSize is the number of rows read per query in full and
+// incremental scans
+const DefaultDBBatchSize = 1000
+
+// Defaults for the number of tables scanned at a time, each holding a
+// connection, and the time any single query may take
+const (
+	DefaultDBMaxConnections = 4
+	DefaultDBQueryTimeout   = 5 * time.Minute
+)
+
+// checkpointColumn is the column incremental scans follow, when a table has
+// it, so updated rows are scanned again
+const checkpointColumn = "updated_at"
+
+// DBConfig holds configuration for database scanning. Schema, table and
+// column filters are globs as understood by path.Match.
+type DBConfig struct {
+	Mode           string // DBModeSample (default), DBModeFull or DBModeIncremental
+	SampleSize     int    // Number of rows to sample from each table in sample mode, 0 for all
+	BatchSize      int    // Rows read per query in full and incremental mode
+	CheckpointFile string // Where incremental scans record progress, see DefaultDBCheckpointFile
+
+	Schemas       []string // Schemas (MySQL databases) to scan, defaults to the current one
+	IncludeTables []string // Only scan these tables, as table or schema.table
+	ExcludeTables []string // Skip these tables, as table or schema.table
+
+	// Skip these columns, as column, table.column or schema.table.column
+	ExcludeColumns []string
+
+	EmitSelect bool // Attach a SELECT statement returning the offending row to each finding
+
+	MaxConnections int           // Tables scanned at a time, each on its own connection
+	QueryTimeout   time.Duration // Time any single query may take, 0 for no limit
+
+	// Also scan routines, views, triggers, column defaults, comments and
+	// foreign server options
+	Metadata bool
+
+	// Bytes of a binary value scanned, including what is decompressed or
+	// unpacked from it, see DefaultDBMaxValueSize
+	MaxValueSize int64
+
+	// Redis keys scanned, as a SCAN MATCH pattern, and the COUNT hint of each
+	// SCAN, see DefaultRedisScanCount
+	RedisMatch string
+	RedisCount int
+}
+
 type DBScanner struct {
-	patterns   []model.Configuration
-	sampleSize int
+	patterns []model.Configuration
+	config   *DBConfig
+
+	// Set for each scan
+	database    string // Identifies the database in checkpoints
+	name        string // Database name in finding locations
+	checkpoints *dbCheckpoints
+
+	// Builds finding locations from the table, column, primary key columns and
+	// primary key values of a row, defaults to
+	// table://db/schema.table/column?pk=id:42
+	locate func(table Table, column string, keys, key []string) string
 }
 
-func NewDBScanner(sampleSize int) *DBScanner {
+func NewDBScanner(config *DBConfig) *DBScanner {
+	if config == nil {
+		config = &DBConfig{}
+	}
 	return &DBScanner{
-		sampleSize: sampleSize,
+		config: config,
 	}
 }
 
@@ -27,195 +100,652 @@ func (s *DBScanner) AddPattern(patterns ...model.Configuration) {
 	s.patterns = append(s.patterns, patterns...)
 }
 
-func (s *DBScanner) parseDBURL(dbURL string) (string, string, error) {
-	if strings.HasPrefix(dbURL, "postgres://") {
-		return "postgres", dbURL, nil
-	} else if strings.HasPrefix(dbURL, "mysql://") {
-		// Convert MySQL URL to DSN format
-		dbURL = strings.TrimPrefix(dbURL, "mysql://")
-		return "mysql", dbURL, nil
+// ScanDatabase scans the selected tables of a database, the collections of a
+// MongoDB database or the keys of a Redis database. Every SQL query runs in a
+// read-only transaction, so the scan can never write.
+func (s *DBScanner) ScanDatabase(ctx context.Context, dbURL string) ([]model.Violation, error) {
+	// SQL dumps are read from the file, as are SQLite databases
+	if strings.HasPrefix(dbURL, "sqldump://") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database URL: %v", err)
+		}
+		return scanSQLDumpFile(ctx, s.patterns, sqliteURLPath(u), s.config)
 	}
-	return "", "", fmt.Errorf("unsupported database type in URL: %s", dbURL)
-}
 
-func (s *DBScanner) ScanDatabase(dbURL string) ([]model.Violation, error) {
-	driverName, dataSourceName, err := s.parseDBURL(dbURL)
+	// MongoDB holds documents rather than tables, Redis keys
+	if isMongoURL(dbURL) {
+		return s.scanMongo(ctx, dbURL)
+	}
+	if isRedisURL(dbURL) {
+		return s.scanRedis(ctx, dbURL)
+	}
+
+	dialect, dataSourceName, err := dialectForURL(dbURL)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open(driverName, dataSourceName)
+	// SQLite findings are located in the file rather than as table://
+	if dialect.Name() == "sqlite" {
+		u, _ := url.Parse(dbURL)
+		return scanSQLite(ctx, s.patterns, sqliteURLPath(u), s.config)
+	}
+
+	db, err := openDB(ctx, dialect, dataSourceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+		return nil, err
 	}
 	defer db.Close()
 
+	// Checkpoints are keyed by the URL without its password, locations use the
+	// database name, or the host when the URL selects no database
+	u, _ := url.Parse(dbURL)
+	s.name = strings.Trim(u.Path, "/")
+	if s.name == "" {
+		s.name = u.Host
+	}
+	return s.scanDB(ctx, db, dialect, u.Redacted())
+}
+
+// openDB connects to a database and checks the connection
+func openDB(ctx context.Context, dialect Dialect, dataSourceName string) (*sql.DB, error) {
+	db, err := sql.Open(dialect.DriverName(), dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
 	// Test connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
+	return db, nil
+}
 
-	var violations []model.Violation
+// scanDB scans the selected tables of an open database
+func (s *DBScanner) scanDB(ctx context.Context, db *sql.DB, dialect Dialect, database string) ([]model.Violation, error) {
+	s.database = database
 
-	// Get all tables
-	tables, err := s.getTables(db, driverName)
+	switch s.config.Mode {
+	case "", DBModeSample, DBModeFull:
+	case DBModeIncremental:
+		file := s.config.CheckpointFile
+		if file == "" {
+			var err error
+			if file, err = DefaultDBCheckpointFile(); err != nil {
+				return nil, err
+			}
+		}
+		checkpoints, err := loadDBCheckpoints(file)
+		if err != nil {
+			return nil, err
+		}
+		s.checkpoints = checkpoints
+	default:
+		return nil, fmt.Errorf("unsupported database scan mode: %s", s.config.Mode)
+	}
+
+	// Get the tables selected by the schema and table filters
+	tables, err := s.selectTables(ctx, db, dialect)
 	if err != nil {
 		return nil, err
 	}
 
-	// Scan each table
-	for _, table := range tables {
-		tableViolations, err := s.scanTable(db, table)
-		if err != nil {
-			fmt.Printf("Error scanning table %s: %v\n", table, err)
-			continue
+	// Scan the tables concurrently, keeping findings in table order
+	results := make([][]model.Violation, len(tables))
+	errs := s.forEachTable(ctx, db, tables, func(q querier, i int) error {
+		var err error
+		results[i], err = s.scanTable(ctx, q, dialect, tables[i])
+		return err
+	})
+
+	var violations []model.Violation
+	for i, table := range tables {
+		if errs[i] != nil {
+			fmt.Printf("Error scanning table %s: %v\n", table, errs[i])
 		}
-		violations = append(violations, tableViolations...)
+		violations = append(violations, results[i]...)
+	}
+
+	if s.config.Metadata {
+		violations = append(violations, s.scanMetadata(ctx, db, dialect)...)
 	}
 
-	return violations, nil
+	return violations, ctx.Err()
 }
 
-func (s *DBScanner) getTables(db *sql.DB, driverName string) ([]string, error) {
-	var query string
-	switch driverName {
-	case "postgres":
-		query = `SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'`
-	case "mysql":
-		query = `SHOW TABLES`
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", driverName)
+// selectTables lists the tables selected by the schema and table filters,
+// in a read-only transaction
+func (s *DBScanner) selectTables(ctx context.Context, db *sql.DB, dialect Dialect) ([]Table, error) {
+	var tables []Table
+	err := s.readOnly(ctx, db, func(q querier) error {
+		queryCtx, cancel := s.config.queryContext(ctx)
+		defer cancel()
+		var err error
+		tables, err = s.config.selectTables(queryCtx, q, dialect)
+		return err
+	})
+	return tables, err
+}
+
+// readOnly runs fn in a read-only transaction, which is rolled back after
+func (s *DBScanner) readOnly(ctx context.Context, db *sql.DB, fn func(q querier) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin read-only transaction: %v", err)
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+// forEachTable calls fn for every table, running at most MaxConnections at a
+// time, each in its own read-only transaction. It returns the error of each
+// table, and stops starting tables when ctx is done.
+func (s *DBScanner) forEachTable(ctx context.Context, db *sql.DB, tables []Table, fn func(q querier, i int) error) []error {
+	db.SetMaxOpenConns(s.config.workers())
+	return s.config.forEach(ctx, len(tables), func(i int) error {
+		return s.readOnly(ctx, db, func(q querier) error {
+			return fn(q, i)
+		})
+	})
+}
+
+// workers returns the number of tables scanned at a time
+func (c *DBConfig) workers() int {
+	if c.MaxConnections <= 0 {
+		return DefaultDBMaxConnections
+	}
+	return c.MaxConnections
+}
+
+// forEach calls fn for 0 to n-1, at most workers() at a time, and returns the
+// error of each call. It stops starting calls when ctx is done.
+func (c *DBConfig) forEach(ctx context.Context, n int, fn func(i int) error) []error {
+	workers := c.workers()
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
 	}
 
-	rows, err := db.Query(query)
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// queryContext limits a query to the configured timeout
+func (c *DBConfig) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.QueryTimeout)
+}
+
+// describeTable returns the columns of a table that are not excluded and its
+// primary key columns
+func (s *DBScanner) describeTable(ctx context.Context, q querier, dialect Dialect, table Table) ([]Column, []string, error) {
+	ctx, cancel := s.config.queryContext(ctx)
+	defer cancel()
+
+	columns, err := s.config.selectColumns(ctx, q, dialect, table)
+	if err != nil || len(columns) == 0 {
+		return nil, nil, err
+	}
+
+	keys, err := dialect.PrimaryKey(ctx, q, table)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to get primary key: %v", err)
 	}
-	defer rows.Close()
+	return columns, keys, nil
+}
 
-	var tables []string
-	for rows.Next() {
-		var table string
-		if err := rows.Scan(&table); err != nil {
-			return nil, err
+// tableScan is what reading the rows of a table needs to know
+type tableScan struct {
+	dialect Dialect
+	table   Table
+	keys    []string // Primary key columns
+	columns []Column // Scanned columns
+
+	// Columns selected ahead of the scanned ones: the columns rows are read in
+	// order of, then primary key columns not among them
+	head     []string
+	order    int   // Number of order columns at the start of head
+	keyIndex []int // Position of each primary key column in head
+
+	filter string // Condition on the rows read, if any
+}
+
+func newTableScan(dialect Dialect, table Table, keys []string, columns []Column, order []string) *tableScan {
+	scan := &tableScan{
+		dialect:  dialect,
+		table:    table,
+		keys:     keys,
+		columns:  columns,
+		head:     append([]string{}, order...),
+		order:    len(order),
+		keyIndex: make([]int, len(keys)),
+	}
+	for i, key := range keys {
+		scan.keyIndex[i] = -1
+		for j, column := range scan.head {
+			if column == key {
+				scan.keyIndex[i] = j
+			}
+		}
+		if scan.keyIndex[i] == -1 {
+			scan.keyIndex[i] = len(scan.head)
+			scan.head = append(scan.head, key)
+		}
+	}
+	return scan
+}
+
+// selectList selects the head columns followed by the scanned columns, as
+// text except for binary columns, which are read as they are
+func (t *tableScan) selectList() string {
+	selects := make([]string, 0, len(t.head)+len(t.columns))
+	for _, column := range t.head {
+		selects = append(selects, t.dialect.CastText(t.dialect.QuoteIdent(column)))
+	}
+	for _, column := range t.columns {
+		if kindOfColumn(column) == columnBinary {
+			selects = append(selects, t.dialect.QuoteIdent(column.Name))
+			continue
 		}
-		tables = append(tables, table)
+		selects = append(selects, t.dialect.CastText(t.dialect.QuoteIdent(column.Name)))
 	}
-	return tables, nil
+	return strings.Join(selects, ", ")
 }
 
-func (s *DBScanner) scanTable(db *sql.DB, tableName string) ([]model.Violation, error) {
+func (s *DBScanner) scanTable(ctx context.Context, q querier, dialect Dialect, table Table) ([]model.Violation, error) {
 	// Get column information
-	columns, err := db.Query(`
-		SELECT column_name, data_type 
-		FROM information_schema.columns 
-		WHERE table_name = $1`, tableName)
-	if err != nil {
+	columns, keys, err := s.describeTable(ctx, q, dialect, table)
+	if err != nil || len(columns) == 0 {
 		return nil, err
 	}
-	defer columns.Close()
 
-	var violations []model.Violation
-	var columnNames []string
-	var columnTypes []string
+	switch s.config.Mode {
+	case DBModeFull:
+		violations, _, err := s.scanKeyset(ctx, q, newTableScan(dialect, table, keys, columns, keys), nil)
+		return violations, err
 
-	// Store column information
-	for columns.Next() {
-		var name, dataType string
-		if err := columns.Scan(&name, &dataType); err != nil {
-			return nil, err
-		}
-		columnNames = append(columnNames, name)
-		columnTypes = append(columnTypes, dataType)
-	}
-
-	// PCI-sensitive column name patterns
-	pciColumnPatterns := []string{
-		"(?i)card[_-]?num",
-		"(?i)cc[_-]?num",
-		"(?i)credit[_-]?card",
-		"(?i)card[_-]?holder",
-		"(?i)cvv",
-		"(?i)cvc",
-		"(?i)ccv",
-		"(?i)cid",
-		"(?i)card[_-]?pin",
-		"(?i)card[_-]?expiry",
-		"(?i)card[_-]?exp",
-		"(?i)merchant[_-]?id",
-	}
-
-	// For each column, check if it might contain PCI data based on name
-	for i, colName := range columnNames {
-		isPCISensitive := false
-		for _, pattern := range pciColumnPatterns {
-			if matched, _ := regexp.MatchString(pattern, colName); matched {
-				isPCISensitive = true
+	case DBModeIncremental:
+		// Following updated_at picks up updated rows, the primary key breaks ties
+		order := keys
+		for _, column := range columns {
+			if column.Name == checkpointColumn {
+				order = append([]string{checkpointColumn}, keys...)
 				break
 			}
 		}
+		if len(order) == 0 {
+			fmt.Printf("Warning: table %s has no primary key or %s column, scanning all rows\n", table, checkpointColumn)
+		}
 
-		// Adjust sample size based on column sensitivity
-		sampleSize := s.sampleSize
-		if isPCISensitive {
-			// Double the sample size for PCI-sensitive columns
-			sampleSize *= 2
-		}
-
-		// Build query with appropriate sampling
-		query := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL", 
-			colName, tableName, colName)
-		
-		if sampleSize > 0 {
-			switch {
-			case strings.HasPrefix(strings.ToLower(columnTypes[i]), "char"),
-				strings.HasPrefix(strings.ToLower(columnTypes[i]), "varchar"),
-				strings.HasPrefix(strings.ToLower(columnTypes[i]), "text"):
-				// For text columns, add length-based filtering to avoid scanning huge text fields
-				query = fmt.Sprintf("%s AND length(%s) BETWEEN 8 AND 50", query, colName)
-			}
-			query = fmt.Sprintf("%s LIMIT %d", query, sampleSize)
+		violations, err := s.scanSince(ctx, q, newTableScan(dialect, table, keys, columns, order), table.String())
+		if err != nil || len(order) == 0 || order[0] != checkpointColumn {
+			return violations, err
 		}
 
-		rows, err := db.Query(query)
+		// Rows with no updated_at are not reached by following it, so they
+		// are read separately, once each by primary key
+		if len(keys) == 0 {
+			fmt.Printf("Warning: table %s has no primary key, rows with a NULL %s are scanned on every run\n", table, checkpointColumn)
+		}
+		nullScan := newTableScan(dialect, table, keys, columns, keys)
+		nullScan.filter = dialect.QuoteIdent(checkpointColumn) + " IS NULL"
+		nullViolations, err := s.scanSince(ctx, q, nullScan, table.String()+" where "+checkpointColumn+" is null")
+		return append(violations, nullViolations...), err
+	}
+
+	sampleSize := s.config.sampleSize(columns)
+	scan := newTableScan(dialect, table, keys, columns, nil)
+	query := fmt.Sprintf("SELECT %s FROM %s", scan.selectList(), quoteTable(dialect, table))
+	if sampleSize > 0 {
+		queryCtx, cancel := s.config.queryContext(ctx)
+		query, err = dialect.SampleQuery(queryCtx, q, table, scan.selectList(), sampleSize)
+		cancel()
 		if err != nil {
-			fmt.Printf("Error querying column %s: %v\n", colName, err)
-			continue
+			return nil, fmt.Errorf("failed to build sample query: %v", err)
 		}
-		defer rows.Close()
+	}
 
-		for rows.Next() {
-			var value sql.NullString
-			if err := rows.Scan(&value); err != nil {
-				fmt.Printf("Error scanning value from column %s: %v\n", colName, err)
-				continue
+	violations, _, _, err := s.scanRows(ctx, q, scan, query, nil)
+	return violations, err
+}
+
+// scanSince reads the rows of a table past the checkpoint of the last
+// incremental scan and moves the checkpoint to the last row read
+func (s *DBScanner) scanSince(ctx context.Context, q querier, scan *tableScan, checkpoint string) ([]model.Violation, error) {
+	order := scan.head[:scan.order]
+	after := s.checkpoints.get(s.database, checkpoint, order)
+	violations, last, err := s.scanKeyset(ctx, q, scan, after)
+	if last != nil {
+		if err := s.checkpoints.set(s.database, checkpoint, order, last); err != nil {
+			fmt.Printf("Error saving checkpoint for table %s: %v\n", scan.table, err)
+		}
+	}
+	return violations, err
+}
+
+// sampleSize returns the number of rows to sample from a table with the given
+// columns, doubled when any column might contain PCI data based on its name
+func (c *DBConfig) sampleSize(columns []Column) int {
+	for _, column := range columns {
+		if matchColumnName(pciColumnPatterns, column.Name) {
+			return c.SampleSize * 2
+		}
+	}
+	return c.SampleSize
+}
+
+// scanKeyset reads a table in batches ordered by its order columns, starting
+// after the row whose order column values are after, or from the first row.
+// Rows with NULL order columns, or outside the filter, are not read.
+// It returns the order column values of the last row read. Tables without
+// order columns are read in a single query.
+func (s *DBScanner) scanKeyset(ctx context.Context, q querier, scan *tableScan, after []string) ([]model.Violation, []string, error) {
+	dialect := scan.dialect
+	from := quoteTable(dialect, scan.table)
+	if scan.order == 0 {
+		query := fmt.Sprintf("SELECT %s FROM %s", scan.selectList(), from)
+		if scan.filter != "" {
+			query += " WHERE " + scan.filter
+		}
+		violations, _, _, err := s.scanRows(ctx, q, scan, query, nil)
+		return violations, nil, err
+	}
+
+	batchSize := s.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultDBBatchSize
+	}
+
+	quoted := make([]string, scan.order)
+	conditions := make([]string, scan.order)
+	for i, column := range scan.head[:scan.order] {
+		quoted[i] = dialect.QuoteIdent(column)
+		conditions[i] = quoted[i] + " IS NOT NULL"
+	}
+	if scan.filter != "" {
+		conditions = append(conditions, scan.filter)
+	}
+
+	var violations []model.Violation
+	last := after
+	for {
+		where := strings.Join(conditions, " AND ")
+		var args []interface{}
+		if last != nil {
+			placeholders := make([]string, len(last))
+			for i, value := range last {
+				placeholders[i] = dialect.Placeholder(i + 1)
+				args = append(args, value)
 			}
+			where = fmt.Sprintf("%s AND (%s) > (%s)", where, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %d",
+			scan.selectList(), from, where, strings.Join(quoted, ", "), batchSize)
 
-			if !value.Valid {
-				continue
+		batch, lastRow, count, err := s.scanRows(ctx, q, scan, query, args)
+		violations = append(violations, batch...)
+		if lastRow != nil {
+			last = lastRow
+		}
+		if err != nil || count < batchSize {
+			return violations, last, err
+		}
+	}
+}
+
+// scanRows runs a query selecting scan.selectList() and checks every scanned
+// value against all patterns. It returns the order column values of the last
+// row and the number of rows read. The query is limited to the configured
+// timeout.
+func (s *DBScanner) scanRows(ctx context.Context, q querier, scan *tableScan, query string, args []interface{}) ([]model.Violation, []string, int, error) {
+	ctx, cancel := s.config.queryContext(ctx)
+	defer cancel()
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer rows.Close()
+
+	// Values are read as bytes, which are nil for NULL
+	values := make([][]byte, len(scan.head)+len(scan.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var violations []model.Violation
+	var last []string
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return violations, last, count, err
+		}
+		count++
+
+		key := make([]string, len(scan.keyIndex))
+		for i, index := range scan.keyIndex {
+			key[i] = string(values[index])
+		}
+		if scan.order > 0 {
+			last = make([]string, scan.order)
+			for i := range last {
+				last[i] = string(values[i])
 			}
+		}
 
-			// Check each pattern against the value
-			for _, pattern := range s.patterns {
-				if pattern.CompiledRegex == nil {
-					fmt.Printf("Warning: Skipping rule %s - regex not compiled\n", pattern.Name)
-					continue
-				}
-				if pattern.CompiledRegex.MatchString(value.String) {
-					context := fmt.Sprintf("Column '%s' contains sensitive data: %s", colName, value.String)
-					violations = append(violations, model.Violation{
-						Rule:     pattern,
-						Match:    value.String,
-						Location: fmt.Sprintf("table://%s/%s", tableName, colName),
-						Context:  context,
-					})
-					
-					// Break after first match for this value to avoid duplicate reports
-					break
-				}
+		for i, column := range scan.columns {
+			value := values[len(scan.head)+i]
+			if len(value) == 0 {
+				continue
 			}
+			violations = append(violations, s.scanValue(scan, column, key, value)...)
 		}
 	}
 
-	return violations, nil
-} 
\ No newline at end of file
+	return violations, last, count, rows.Err()
+}
+
+// matchValue checks a value against all patterns and reports the first match
+func (s *DBScanner) matchValue(scan *tableScan, column string, key []string, value string) (model.Violation, bool) {
+	for _, pattern := range s.patterns {
+		if pattern.CompiledRegex == nil {
+			fmt.Printf("Warning: Skipping rule %s - regex not compiled\n", pattern.Name)
+			continue
+		}
+		match := pattern.CompiledRegex.FindString(value)
+		if match == "" {
+			continue
+		}
+
+		location := s.rowLocation(scan, column, key)
+		if s.locate != nil {
+			location = s.locate(scan.table, column, scan.keys, key)
+		}
+		v := model.Violation{
+			Rule:     pattern,
+			Match:    match,
+			Location: location,
+			Context:  fmt.Sprintf("Column '%s' contains sensitive data: %s", column, getMatchContext(value, match)),
+		}
+		if s.config.EmitSelect {
+			v.Query = rowQuery(scan, column, key, match)
+		}
+		return v, true
+	}
+	return model.Violation{}, false
+}
+
+// rowLocation locates a value as table://db/schema.table/column?pk=id:42, with
+// a comma-separated name:value pair per primary key column
+func (s *DBScanner) rowLocation(scan *tableScan, column string, key []string) string {
+	return fmt.Sprintf("table://%s/%s/%s", s.name, scan.table, column) + keyQuery(scan.keys, key)
+}
+
+// keyQuery returns ?pk=id:42 for the primary key columns and values of a row,
+// or nothing for tables without a primary key
+func keyQuery(keys, key []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(keys))
+	for i, name := range keys {
+		pairs[i] = url.QueryEscape(name) + ":" + url.QueryEscape(key[i])
+	}
+	return "?pk=" + strings.Join(pairs, ",")
+}
+
+// rowQuery returns a SELECT statement for the row a value was found in. Rows
+// of tables without a primary key are matched on the value instead, which may
+// return other rows as well.
+func rowQuery(scan *tableScan, column string, key []string, match string) string {
+	dialect := scan.dialect
+	var conditions []string
+	for i, name := range scan.keys {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", dialect.QuoteIdent(name), sqlString(dialect, key[i])))
+	}
+	if len(conditions) == 0 {
+		conditions = append(conditions, fmt.Sprintf("%s LIKE %s",
+			dialect.CastText(dialect.QuoteIdent(column)), sqlString(dialect, "%"+match+"%")))
+	}
+	return fmt.Sprintf("SELECT * FROM %s WHERE %s;", quoteTable(dialect, scan.table), strings.Join(conditions, " AND "))
+}
+
+// sqlString quotes a string literal. MySQL also treats backslashes in string
+// literals as escapes.
+func sqlString(dialect Dialect, value string) string {
+	value = strings.ReplaceAll(value, "'", "''")
+	if _, ok := dialect.(mysqlDialect); ok {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + value + "'"
+}
+
+// selectTables lists the tables selected by the schema and table filters
+func (c *DBConfig) selectTables(ctx context.Context, q querier, dialect Dialect) ([]Table, error) {
+	schemas, err := c.selectSchemas(ctx, q, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := dialect.Tables(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []Table
+	for _, table := range tables {
+		if c.selectTable(schemas, table) {
+			selected = append(selected, table)
+		}
+	}
+	return selected, nil
+}
+
+// selectSchemas returns the schema globs to scan, defaulting to the current
+// schema. It returns nothing when all schemas are scanned.
+func (c *DBConfig) selectSchemas(ctx context.Context, q querier, dialect Dialect) ([]string, error) {
+	if len(c.Schemas) > 0 {
+		return c.Schemas, nil
+	}
+	current, err := dialect.CurrentSchema(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current schema: %v", err)
+	}
+	// MySQL URLs may not select a database, then all are scanned
+	if current == "" {
+		return nil, nil
+	}
+	return []string{current}, nil
+}
+
+// selectColumns lists the columns of a table that are not excluded
+func (c *DBConfig) selectColumns(ctx context.Context, q querier, dialect Dialect, table Table) ([]Column, error) {
+	columns, err := dialect.Columns(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []Column
+	for _, column := range columns {
+		if !c.excludeColumn(table, column.Name) {
+			selected = append(selected, column)
+		}
+	}
+	return selected, nil
+}
+
+// excludeColumn reports whether a column matches the column exclusions
+func (c *DBConfig) excludeColumn(table Table, column string) bool {
+	for _, pattern := range c.ExcludeColumns {
+		// The qualification of the pattern decides what it is matched against
+		name := column
+		switch strings.Count(pattern, ".") {
+		case 0:
+		case 1:
+			name = table.Name + "." + column
+		default:
+			name = table.Schema + "." + table.Name + "." + column
+		}
+		if matchGlobs([]string{pattern}, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectTable reports whether a table passes the schema and table filters,
+// given the schema globs returned by selectSchemas
+func (c *DBConfig) selectTable(schemas []string, table Table) bool {
+	if len(schemas) > 0 && !matchGlobs(schemas, table.Schema) {
+		return false
+	}
+	if len(c.IncludeTables) > 0 && !matchTableGlobs(c.IncludeTables, table) {
+		return false
+	}
+	return !matchTableGlobs(c.ExcludeTables, table)
+}
+
+// matchTableGlobs reports whether a table matches any of the patterns, which
+// are matched against schema.table when qualified and the table name otherwise
+func matchTableGlobs(patterns []string, table Table) bool {
+	for _, pattern := range patterns {
+		name := table.Name
+		if strings.Contains(pattern, ".") {
+			name = table.String()
+		}
+		if matchGlobs([]string{pattern}, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobs reports whether name matches any of the glob patterns
+func matchGlobs(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
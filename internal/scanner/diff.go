@@ -0,0 +1,226 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// gitCommit identifies the commit a diff belongs to
+type gitCommit struct {
+	SHA    string
+	Author string // Name <email>
+	Date   string // Strict ISO 8601
+}
+
+// diffFile holds the lines a diff adds to one file
+type diffFile struct {
+	Commit *gitCommit // nil for plain unified diffs
+	Path   string     // Path of the file after the change
+	Blob   string     // Object ID of the file after the change, if known
+	Added  []Segment  // Added lines, numbered as in the new file
+}
+
+// commitHeaderPrefix starts the commit header lines git log is asked to print
+// before each commit's diff (see gitLogFormat)
+const commitHeaderPrefix = "\x00commit\x00"
+
+// gitLogFormat prints commitHeaderPrefix followed by the SHA, author and date
+// separated by null bytes
+const gitLogFormat = "--format=%x00commit%x00%H%x00%an <%ae>%x00%aI"
+
+// parseDiff reads a unified diff, optionally interleaved with commit headers
+// from git log, and calls onFile with the added lines of each changed file.
+// Removed and context lines are only used to keep new-file line numbers.
+func parseDiff(r io.Reader, onFile func(diffFile) error) error {
+	reader := bufio.NewReader(r)
+
+	var commit *gitCommit
+	var file *diffFile
+	newLine := 0
+
+	// Lines of the current hunk not yet seen, from its header
+	oldRemaining, newRemaining := 0, 0
+
+	flush := func() error {
+		if file != nil && file.Path != "" && len(file.Added) > 0 {
+			if err := onFile(*file); err != nil {
+				return err
+			}
+		}
+		file = nil
+		oldRemaining, newRemaining = 0, 0
+		return nil
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		inHunk := file != nil && (oldRemaining > 0 || newRemaining > 0)
+
+		switch {
+		case strings.HasPrefix(line, commitHeaderPrefix):
+			if err := flush(); err != nil {
+				return err
+			}
+			fields := strings.Split(strings.TrimPrefix(line, commitHeaderPrefix), "\x00")
+			for len(fields) < 3 {
+				fields = append(fields, "")
+			}
+			commit = &gitCommit{SHA: fields[0], Author: fields[1], Date: fields[2]}
+
+		case strings.HasPrefix(line, "diff --git "):
+			if err := flush(); err != nil {
+				return err
+			}
+			file = &diffFile{Commit: commit}
+
+		case inHunk && strings.HasPrefix(line, "+"):
+			file.Added = append(file.Added, Segment{Text: line[1:], LineNum: newLine, Col: 1})
+			newLine++
+			newRemaining--
+
+		case inHunk && strings.HasPrefix(line, "-"):
+			oldRemaining--
+
+		case inHunk && (strings.HasPrefix(line, " ") || line == ""):
+			// Context line, some tools strip the space from empty ones
+			newLine++
+			oldRemaining--
+			newRemaining--
+
+		case strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file"
+
+		case strings.HasPrefix(line, "@@ "):
+			start, oldCount, newCount, hunkErr := parseHunkHeader(line)
+			if hunkErr != nil {
+				return hunkErr
+			}
+			oldRemaining, newRemaining = oldCount, newCount
+			if file == nil {
+				// Plain diffs (e.g. diff -u) have no "diff --git" line
+				file = &diffFile{Commit: commit}
+			}
+			newLine = start
+
+		case strings.HasPrefix(line, "--- "):
+			// In plain diffs the "---" header is the first line of a file
+			if file == nil || len(file.Added) > 0 || file.Path != "" {
+				if err := flush(); err != nil {
+					return err
+				}
+				file = &diffFile{Commit: commit}
+			}
+
+		case file != nil && strings.HasPrefix(line, "+++ "):
+			file.Path = parseDiffPath(strings.TrimPrefix(line, "+++ "))
+
+		case file != nil && strings.HasPrefix(line, "index "):
+			// index <old>..<new> [mode]
+			ids := strings.Fields(strings.TrimPrefix(line, "index "))
+			if len(ids) > 0 {
+				if i := strings.Index(ids[0], ".."); i != -1 {
+					file.Blob = ids[0][i+2:]
+				}
+			}
+
+		default:
+			// Other header lines (mode changes, renames, binary notices) and
+			// commit messages
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return flush()
+}
+
+// matchDiffFile checks the added lines of a file against all patterns and
+// attributes findings to the file's commit. Lines marked with AllowComment
+// are skipped.
+func matchDiffFile(patterns []model.Configuration, location string, file diffFile, report func(model.Violation)) {
+	added := make([]Segment, 0, len(file.Added))
+	for _, segment := range file.Added {
+		if !strings.Contains(segment.Text, AllowComment) {
+			added = append(added, segment)
+		}
+	}
+
+	matchSegments(patterns, location, added, func(v model.Violation) {
+		if file.Commit != nil {
+			v.Commit = file.Commit.SHA
+			v.Author = file.Commit.Author
+			v.CommitDate = file.Commit.Date
+		}
+		report(v)
+	})
+}
+
+// parseHunkHeader returns the new-file start line and the old and new line
+// counts of "@@ -a,b +c,d @@"
+func parseHunkHeader(line string) (start, oldCount, newCount int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+
+	_, oldCount, err = parseHunkRange(fields[1][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header %q: %v", line, err)
+	}
+	start, newCount, err = parseHunkRange(fields[2][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid hunk header %q: %v", line, err)
+	}
+	return start, oldCount, newCount, nil
+}
+
+// parseHunkRange parses "start,count", where count defaults to 1
+func parseHunkRange(r string) (int, int, error) {
+	parts := strings.SplitN(r, ",", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if len(parts) == 2 {
+		if count, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// parseDiffPath returns the path of a "+++" header, without the "b/" prefix,
+// or "" for deleted files
+func parseDiffPath(path string) string {
+	// Plain diffs may add a timestamp after a tab
+	if i := strings.Index(path, "\t"); i != -1 {
+		path = path[:i]
+	}
+	if strings.HasPrefix(path, "\"") {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
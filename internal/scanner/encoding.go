@@ -0,0 +1,189 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoding identifies the character encoding of scanned content
+type TextEncoding string
+
+const (
+	EncodingUTF8    TextEncoding = "utf-8"
+	EncodingUTF16LE TextEncoding = "utf-16le"
+	EncodingUTF16BE TextEncoding = "utf-16be"
+	EncodingLatin1  TextEncoding = "latin-1"
+)
+
+// encodingSampleSize is how much content is inspected to guess the encoding
+const encodingSampleSize = 4096
+
+// detectEncoding guesses the encoding of content from its byte order mark, or
+// from the position of null bytes and the validity of UTF-8 when there is none.
+// It also returns the length of the BOM, which is not part of the text.
+func detectEncoding(sample []byte) (TextEncoding, int) {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}):
+		return EncodingUTF8, 3
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return EncodingUTF16LE, 2
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return EncodingUTF16BE, 2
+	}
+
+	// ASCII text stored as UTF-16 has a null byte in every other position
+	var evenNulls, oddNulls int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNulls++
+		} else {
+			oddNulls++
+		}
+	}
+	pairs := len(sample) / 2
+	if pairs > 0 {
+		switch {
+		case oddNulls > pairs*2/5 && evenNulls < pairs/10:
+			return EncodingUTF16LE, 0
+		case evenNulls > pairs*2/5 && oddNulls < pairs/10:
+			return EncodingUTF16BE, 0
+		}
+	}
+
+	// Anything with null bytes left is binary, which is scanned as-is
+	if evenNulls+oddNulls > 0 || validUTF8Prefix(sample) {
+		return EncodingUTF8, 0
+	}
+	return EncodingLatin1, 0
+}
+
+// validUTF8Prefix reports whether sample is valid UTF-8, ignoring a rune that
+// was cut off at the end of the sample
+func validUTF8Prefix(sample []byte) bool {
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			return !utf8.FullRune(sample[i:])
+		}
+		i += size
+	}
+	return true
+}
+
+// newDecodingReader detects the encoding of r and returns a reader producing
+// its content as UTF-8, without the byte order mark
+func newDecodingReader(r io.Reader) (io.Reader, TextEncoding) {
+	buffered := bufio.NewReaderSize(r, encodingSampleSize)
+	sample, _ := buffered.Peek(encodingSampleSize)
+
+	encoding, bomLen := detectEncoding(sample)
+	buffered.Discard(bomLen)
+
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return &utf16Reader{src: buffered, bigEndian: encoding == EncodingUTF16BE}, encoding
+	case EncodingLatin1:
+		return &latin1Reader{src: buffered}, encoding
+	default:
+		return buffered, encoding
+	}
+}
+
+// columns returns how many code units of the original encoding the UTF-8 text
+// took up: bytes for UTF-8 and Latin-1, 16-bit units for UTF-16. It is used to
+// map columns in transcoded text back to the original file.
+func (e TextEncoding) columns(text string) int {
+	switch e {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		n := 0
+		for _, r := range text {
+			n += utf16.RuneLen(r)
+		}
+		return n
+	case EncodingLatin1:
+		return utf8.RuneCountInString(text)
+	default:
+		return len(text)
+	}
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8
+type utf16Reader struct {
+	src       io.Reader
+	bigEndian bool
+	in        []byte // Undecoded input carried over from the last read
+	out       []byte // Decoded output not yet returned
+	err       error
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		buf := make([]byte, encodingSampleSize)
+		n, err := r.src.Read(buf)
+		r.in = append(r.in, buf[:n]...)
+		r.err = err
+
+		units := make([]uint16, 0, len(r.in)/2)
+		for len(r.in) >= 2 {
+			if r.bigEndian {
+				units = append(units, uint16(r.in[0])<<8|uint16(r.in[1]))
+			} else {
+				units = append(units, uint16(r.in[1])<<8|uint16(r.in[0]))
+			}
+			r.in = r.in[2:]
+		}
+
+		// Keep a high surrogate until its pair arrives
+		if len(units) > 0 && r.err == nil && units[len(units)-1] >= 0xd800 && units[len(units)-1] < 0xdc00 {
+			last := units[len(units)-1]
+			units = units[:len(units)-1]
+			r.in = append([]byte{0, 0}, r.in...)
+			if r.bigEndian {
+				r.in[0], r.in[1] = byte(last>>8), byte(last)
+			} else {
+				r.in[0], r.in[1] = byte(last), byte(last>>8)
+			}
+		}
+
+		for _, rn := range utf16.Decode(units) {
+			r.out = utf8.AppendRune(r.out, rn)
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// latin1Reader transcodes ISO-8859-1 to UTF-8
+type latin1Reader struct {
+	src io.Reader
+	out []byte
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 {
+		buf := make([]byte, len(p))
+		n, err := r.src.Read(buf)
+		for _, b := range buf[:n] {
+			r.out = utf8.AppendRune(r.out, rune(b))
+		}
+		if len(r.out) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeUTF16(text string, bigEndian bool) []byte {
+	var buf []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		if bigEndian {
+			buf = append(buf, byte(unit>>8), byte(unit))
+		} else {
+			buf = append(buf, byte(unit), byte(unit>>8))
+		}
+	}
+	return buf
+}
+
+func TestNewDecodingReader(t *testing.T) {
+	text := "$env:TOKEN = \"ghp_abc\" # 🔑\r\nWrite-Host 'ok'\r\n"
+
+	tests := []struct {
+		name     string
+		content  []byte
+		expected string
+		encoding TextEncoding
+	}{
+		{"utf-8", []byte(text), text, EncodingUTF8},
+		{"utf-8 with bom", append([]byte{0xef, 0xbb, 0xbf}, text...), text, EncodingUTF8},
+		{"utf-16le with bom", append([]byte{0xff, 0xfe}, encodeUTF16(text, false)...), text, EncodingUTF16LE},
+		{"utf-16be with bom", append([]byte{0xfe, 0xff}, encodeUTF16(text, true)...), text, EncodingUTF16BE},
+		{"utf-16le without bom", encodeUTF16(text, false), text, EncodingUTF16LE},
+		{"latin-1", []byte("password=caf\xe9\n"), "password=café\n", EncodingLatin1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, encoding := newDecodingReader(bytes.NewReader(tt.content))
+			decoded, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.expected, string(decoded))
+		})
+	}
+}
+
+func TestEncodingColumns(t *testing.T) {
+	assert.Equal(t, 9, EncodingUTF8.columns("café ✓"))
+	assert.Equal(t, 6, EncodingLatin1.columns("café ✓"))
+	assert.Equal(t, 3, EncodingUTF16LE.columns("a🔑"))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -22,8 +23,19 @@ const (
 	FormatXML  FileFormat = "xml"
 	FormatINI  FileFormat = "ini"
 	FormatEnv  FileFormat = "env"
+
+	FormatNotebook FileFormat = "notebook"
 )
 
+// Segment is a piece of scannable text extracted from a file, along with
+// where it was found
+type Segment struct {
+	Text    string // The extracted text
+	Path    string // Structural path within the file (e.g. notebook cell), if any
+	LineNum int    // Line number of the text in the file, or within the cell for notebooks
+	Col     int    // Column the text starts at, 0 when it is not a whole line
+}
+
 // detectFileFormat determines the file format based on extension and content
 func detectFileFormat(fileName string, content []byte) FileFormat {
 	ext := strings.ToLower(filepath.Ext(fileName))
@@ -35,12 +47,23 @@ func detectFileFormat(fileName string, content []byte) FileFormat {
 		return FormatYAML
 	case ".xml":
 		return FormatXML
-	case ".ini", ".conf", ".config":
+	case ".ini", ".conf":
+		return FormatINI
+	case ".config":
+		// web.config and app.config are XML, other .config files are key=value
+		if looksLikeXML(content) {
+			return FormatXML
+		}
 		return FormatINI
 	case ".env":
 		return FormatEnv
+	case ".ipynb":
+		return FormatNotebook
 	default:
-		// Try to detect JSON/YAML from content
+		// Try to detect XML/JSON/YAML from content
+		if hasXMLDeclaration(content) {
+			return FormatXML
+		}
 		if isJSON(content) {
 			return FormatJSON
 		}
@@ -51,6 +74,17 @@ func detectFileFormat(fileName string, content []byte) FileFormat {
 	}
 }
 
+// structuredFormat reports whether a file is scanned through its format
+// extractor rather than line by line, based on its name and first bytes
+func structuredFormat(fileName string, header []byte) (FileFormat, bool) {
+	switch format := detectFileFormat(fileName, header); format {
+	case FormatNotebook, FormatXML:
+		return format, true
+	default:
+		return format, false
+	}
+}
+
 // isJSON checks if content is valid JSON
 func isJSON(content []byte) bool {
 	var js json.RawMessage
@@ -64,28 +98,46 @@ func isYAML(content []byte) bool {
 }
 
 // extractTextFromFile extracts scannable text based on file format
-func extractTextFromFile(fileName string, reader io.Reader) ([]string, error) {
-	content, err := io.ReadAll(reader)
+func extractTextFromFile(fileName string, reader io.Reader) ([]Segment, error) {
+	decoded, _ := newDecodingReader(reader)
+	content, err := io.ReadAll(decoded)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file content: %v", err)
 	}
 
+	var lines []string
 	format := detectFileFormat(fileName, content)
 	
 	switch format {
+	case FormatNotebook:
+		return extractFromNotebook(content)
 	case FormatJSON:
-		return extractFromJSON(content)
+		lines, err = extractFromJSON(content)
 	case FormatYAML:
-		return extractFromYAML(content)
+		lines, err = extractFromYAML(content)
 	case FormatXML:
 		return extractFromXML(content)
 	case FormatINI:
-		return extractFromINI(content)
+		lines, err = extractFromINI(content)
 	case FormatEnv:
-		return extractFromEnv(content)
+		lines, err = extractFromEnv(content)
 	default:
-		return extractFromText(content)
+		lines, err = extractFromText(content)
+	}
+	if err != nil {
+		return nil, err
 	}
+
+	return linesToSegments(lines), nil
+}
+
+// linesToSegments wraps extracted lines into segments numbered in order
+func linesToSegments(lines []string) []Segment {
+	segments := make([]Segment, 0, len(lines))
+	for i, line := range lines {
+		segments = append(segments, Segment{Text: line, LineNum: i + 1})
+	}
+	return segments
 }
 
 // extractFromJSON extracts values from JSON content
@@ -114,45 +166,6 @@ func extractFromYAML(content []byte) ([]string, error) {
 	return result, nil
 }
 
-// extractFromXML extracts text content from XML
-func extractFromXML(content []byte) ([]string, error) {
-	// Simple XML parsing - extract text between tags and attribute values
-	var result []string
-	text := string(content)
-	
-	// Extract text between tags
-	inTag := false
-	var buffer strings.Builder
-	
-	for _, char := range text {
-		if char == '<' {
-			if buffer.Len() > 0 {
-				result = append(result, strings.TrimSpace(buffer.String()))
-				buffer.Reset()
-			}
-			inTag = true
-			continue
-		}
-		if char == '>' {
-			inTag = false
-			continue
-		}
-		if !inTag {
-			buffer.WriteRune(char)
-		}
-	}
-
-	// Extract attribute values
-	parts := strings.Split(text, "\"")
-	for i := 1; i < len(parts); i += 2 {
-		if len(parts[i]) > 0 {
-			result = append(result, parts[i])
-		}
-	}
-
-	return result, nil
-}
-
 // extractFromINI extracts values from INI/config content
 func extractFromINI(content []byte) ([]string, error) {
 	var result []string
@@ -237,4 +250,41 @@ func extractValues(result *[]string, data interface{}) {
 	case nil:
 		// Skip nil values
 	}
-} 
\ No newline at end of file
+}
+
+// pathValue is a scalar value of a document and the key path leading to it
+type pathValue struct {
+	Path  string
+	Value string
+}
+
+// extractPathValues recursively extracts scalar values like extractValues,
+// along with their key path, such as db.replicas[0].password. Keys are
+// visited in sorted order.
+func extractPathValues(result *[]pathValue, path string, data interface{}) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			extractPathValues(result, child, v[key])
+		}
+	case []interface{}:
+		for i, item := range v {
+			extractPathValues(result, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case string:
+		if strings.TrimSpace(v) != "" {
+			*result = append(*result, pathValue{Path: path, Value: v})
+		}
+	case float64, bool:
+		*result = append(*result, pathValue{Path: path, Value: fmt.Sprintf("%v", v)})
+	}
+}
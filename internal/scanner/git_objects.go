@@ -0,0 +1,228 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// How a git object is reachable, from most to least visible
+const (
+	ReachableRef          = "ref"         // From a branch, tag or other ref
+	ReachableStash        = "stash"       // Only from a stash entry
+	ReachableReflog       = "reflog"      // Only from reflog entries, e.g. after a rebase or force-push
+	ReachableUnreferenced = "unreachable" // Dangling, kept until garbage collection
+)
+
+// gitBlob is a blob in the object database
+type gitBlob struct {
+	id           string
+	size         int64
+	path         string // A path the blob was committed under, if known
+	reachability string
+}
+
+// ScanAllObjects scans every blob in the object database, loose or packed,
+// including blobs only reachable from stash entries or reflogs and blobs no
+// longer reachable at all. Each finding is labeled with how its blob is
+// reachable. Blobs larger than MaxBlobSize are skipped.
+func (s *GitScanner) ScanAllObjects(ctx context.Context) ([]model.Violation, error) {
+	blobs, err := s.listBlobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Label blobs with the most visible way they can be reached
+	stashCommits, err := s.stashCommits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	walks := []struct {
+		reachability string
+		args         []string
+	}{
+		{ReachableRef, []string{"--exclude=refs/stash", "--all"}},
+		{ReachableStash, stashCommits},
+		{ReachableReflog, []string{"--reflog"}},
+	}
+	for _, walk := range walks {
+		if len(walk.args) == 0 {
+			continue
+		}
+		err := s.walkObjects(ctx, walk.args, func(id, path string) {
+			blob, ok := blobs[id]
+			if !ok || blob.reachability != "" {
+				return
+			}
+			blob.reachability = walk.reachability
+			blob.path = path
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var violations []model.Violation
+	contentScanner := NewSecretScanner()
+	contentScanner.AddPattern(s.patterns...)
+
+	err = s.readBlobs(ctx, blobs, func(blob *gitBlob, content io.Reader) {
+		if blob.reachability == "" {
+			blob.reachability = ReachableUnreferenced
+		}
+
+		// The path drives format detection; unreachable blobs have none
+		name := blob.id
+		location := filepath.Join(s.repoPath, blob.id)
+		if blob.path != "" {
+			name = blob.path
+			location = filepath.Join(s.repoPath, filepath.FromSlash(blob.path))
+		}
+
+		for _, v := range contentScanner.ScanReader(content, name) {
+			v.Location = location
+			v.Blob = blob.id
+			v.Reachability = blob.reachability
+			violations = append(violations, v)
+
+			if s.verbose {
+				fmt.Printf("Found potential %s in %s:%d (blob %s, %s)\n", v.Rule.Name, location, v.LineNum, shortSHA(blob.id), blob.reachability)
+			}
+		}
+	})
+
+	return violations, err
+}
+
+// listBlobs returns every blob in the object database that is small enough
+// to scan
+func (s *GitScanner) listBlobs(ctx context.Context) (map[string]*gitBlob, error) {
+	blobs := make(map[string]*gitBlob)
+
+	err := s.gitStream(ctx, func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 || fields[1] != "blob" {
+				continue
+			}
+			size, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			if s.config.MaxBlobSize > 0 && size > s.config.MaxBlobSize {
+				continue
+			}
+			blobs[fields[0]] = &gitBlob{id: fields[0], size: size}
+		}
+		return scanner.Err()
+	}, "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype) %(objectsize)")
+
+	return blobs, err
+}
+
+// stashCommits returns the commits of every stash entry, not only the latest
+func (s *GitScanner) stashCommits(ctx context.Context) ([]string, error) {
+	if _, err := s.git(ctx, "rev-parse", "--verify", "--quiet", "refs/stash"); err != nil {
+		// No stash
+		return nil, nil
+	}
+
+	output, err := s.git(ctx, "log", "--walk-reflogs", "--format=%H", "refs/stash")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// walkObjects lists the objects reachable from the given rev-list arguments
+// along with the path they were found under
+func (s *GitScanner) walkObjects(ctx context.Context, revs []string, visit func(id, path string)) error {
+	args := append([]string{"rev-list", "--objects"}, revs...)
+	return s.gitStream(ctx, func(r io.Reader) error {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line = strings.TrimSuffix(line, "\n"); line != "" {
+				id, path, _ := strings.Cut(line, " ")
+				visit(id, path)
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}, args...)
+}
+
+// readBlobs streams the content of blobs through a single git cat-file --batch
+// process
+func (s *GitScanner) readBlobs(ctx context.Context, blobs map[string]*gitBlob, consume func(*gitBlob, io.Reader)) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", s.repoPath, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting git: %v", err)
+	}
+
+	ids := make([]string, 0, len(blobs))
+	for id := range blobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Requests are written concurrently so git never blocks on a full pipe
+	go func() {
+		defer stdin.Close()
+		w := bufio.NewWriter(stdin)
+		for _, id := range ids {
+			fmt.Fprintln(w, id)
+		}
+		w.Flush()
+	}()
+
+	reader := bufio.NewReader(stdout)
+	for range ids {
+		// <id> blob <size>, or <id> missing
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("error reading git objects: %v", err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		content := io.LimitReader(reader, size)
+		if blob, ok := blobs[fields[0]]; ok {
+			consume(blob, content)
+		}
+		// Skip whatever was not read, plus the newline after the content
+		io.Copy(io.Discard, content)
+		reader.Discard(1)
+	}
+
+	return cmd.Wait()
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// RefUpdate is a ref changed by a push, as passed to a pre-receive hook
+type RefUpdate struct {
+	Old string
+	New string
+	Ref string
+}
+
+// ParseRefUpdates reads the "<old> <new> <ref>" lines a pre-receive hook
+// receives on stdin
+func ParseRefUpdates(r io.Reader) ([]RefUpdate, error) {
+	var updates []RefUpdate
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid ref update: %q", line)
+		}
+		updates = append(updates, RefUpdate{Old: fields[0], New: fields[1], Ref: fields[2]})
+	}
+
+	return updates, scanner.Err()
+}
+
+// ScanPush scans the commits introduced by a push, i.e. the commits reachable
+// from the new ref values that no existing ref reaches. It is meant to run in
+// a pre-receive hook, where pushed objects are readable but refs are not
+// updated yet. Deleted refs are skipped.
+func (s *GitScanner) ScanPush(ctx context.Context, updates []RefUpdate) ([]model.Violation, error) {
+	var revs []string
+	for _, update := range updates {
+		if isZeroSHA(update.New) {
+			continue
+		}
+		revs = append(revs, update.New)
+	}
+	if len(revs) == 0 {
+		return nil, nil
+	}
+
+	args := gitLogArgs()
+	args = append(args, revs...)
+	args = append(args, "--not", "--all", "--")
+
+	return s.scanGitDiff(ctx, args...)
+}
+
+// isZeroSHA reports whether sha is the all-zero id git uses for a missing
+// ref, such as the old value of a new branch
+func isZeroSHA(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
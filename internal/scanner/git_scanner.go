@@ -0,0 +1,209 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// AllowComment marks a line as reviewed so git scans do not report it, e.g.
+// `api_key = "test-fixture-key" # blacklight:allow`
+const AllowComment = "blacklight:allow"
+
+// GitConfig holds configuration for git history scanning
+type GitConfig struct {
+	Since      string // Only commits more recent than this date (git date format)
+	Until      string // Only commits older than this date
+	Branch     string // Only commits reachable from this branch, defaults to all refs
+	MaxCommits int    // Maximum number of commits to scan, 0 for no limit
+
+	// Largest blob scanned by ScanAllObjects in bytes, 0 for no limit
+	MaxBlobSize int64
+
+	// Extra git log arguments. Those starting with "-" are options, given as
+	// --name=value; the rest are revisions such as a base..head range, which
+	// are scanned along with Branch and replace the default of all refs.
+	LogOpts []string
+}
+
+// GitScanner scans the history of a git repository. It shells out to the git
+// binary, which handles every repository layout and object storage format.
+type GitScanner struct {
+	repoPath string
+	config   *GitConfig
+	patterns []model.Configuration
+	verbose  bool
+}
+
+// NewGitScanner creates a scanner for the repository containing repoPath
+func NewGitScanner(repoPath string, config *GitConfig) (*GitScanner, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is required for repository scanning: %v", err)
+	}
+
+	if config == nil {
+		config = &GitConfig{}
+	}
+
+	s := &GitScanner{repoPath: repoPath, config: config}
+	if _, err := s.git(context.Background(), "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %v", repoPath, err)
+	}
+
+	return s, nil
+}
+
+func (s *GitScanner) AddPattern(patterns ...model.Configuration) {
+	s.patterns = append(s.patterns, patterns...)
+}
+
+func (s *GitScanner) SetVerbose(verbose bool) {
+	s.verbose = verbose
+}
+
+// gitLogArgs returns the git log arguments printing the lines each commit
+// adds. Merges are diffed against their first parent, so content added while
+// resolving them is scanned too. Commits are read oldest first, so findings
+// are attributed to the commit that first added a blob rather than a later
+// merge carrying it.
+func gitLogArgs() []string {
+	return []string{"log", "-p", "-U0", "--full-index", "--no-color", "--no-ext-diff", "--no-renames",
+		"--diff-merges=first-parent", "--topo-order", "--reverse", gitLogFormat}
+}
+
+// ScanHistory scans the lines added by every commit reachable from the
+// configured refs. Each file version (blob) is only scanned once, so files
+// carried over by merges, cherry-picks and reverts are not scanned again.
+func (s *GitScanner) ScanHistory(ctx context.Context) ([]model.Violation, error) {
+	args := gitLogArgs()
+	if s.config.Since != "" {
+		args = append(args, "--since="+s.config.Since)
+	}
+	if s.config.Until != "" {
+		args = append(args, "--until="+s.config.Until)
+	}
+	if s.config.MaxCommits > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", s.config.MaxCommits))
+	}
+
+	var revisions []string
+	if s.config.Branch != "" {
+		revisions = append(revisions, s.config.Branch)
+	}
+	for _, opt := range s.config.LogOpts {
+		if strings.HasPrefix(opt, "-") {
+			args = append(args, opt)
+		} else {
+			revisions = append(revisions, opt)
+		}
+	}
+	if len(revisions) == 0 {
+		args = append(args, "--all")
+	}
+
+	// Revisions are never read as options, even when they start with "-"
+	args = append(args, "--end-of-options")
+	args = append(args, revisions...)
+	args = append(args, "--")
+
+	return s.scanGitDiff(ctx, args...)
+}
+
+// ScanStaged scans the lines added in the index relative to HEAD, as a
+// pre-commit hook would. Line numbers refer to the staged version of files.
+func (s *GitScanner) ScanStaged(ctx context.Context) ([]model.Violation, error) {
+	return s.scanGitDiff(ctx, "diff", "--cached", "-U0", "--full-index", "--no-color", "--no-ext-diff", "--no-renames", "--diff-filter=d")
+}
+
+// scanGitDiff runs a git command printing a diff and scans the added lines
+func (s *GitScanner) scanGitDiff(ctx context.Context, args ...string) ([]model.Violation, error) {
+	var violations []model.Violation
+	scannedBlobs := make(map[string]bool)
+
+	err := s.gitStream(ctx, func(r io.Reader) error {
+		return parseDiff(r, func(file diffFile) error {
+			if file.Blob != "" && !isZeroSHA(file.Blob) {
+				if scannedBlobs[file.Blob] {
+					return nil
+				}
+				scannedBlobs[file.Blob] = true
+			}
+
+			violations = append(violations, s.scanDiffFile(file)...)
+			return nil
+		})
+	}, args...)
+
+	return violations, err
+}
+
+// scanDiffFile checks the added lines of a file against all patterns
+func (s *GitScanner) scanDiffFile(file diffFile) []model.Violation {
+	var violations []model.Violation
+
+	location := filepath.Join(s.repoPath, filepath.FromSlash(file.Path))
+	matchDiffFile(s.patterns, location, file, func(v model.Violation) {
+		violations = append(violations, v)
+
+		if s.verbose {
+			fmt.Printf("Found potential %s in %s:%d (commit %s)\n", v.Rule.Name, location, v.LineNum, shortSHA(v.Commit))
+		}
+	})
+
+	return violations
+}
+
+// git runs a git command in the repository and returns its output
+func (s *GitScanner) git(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.repoPath, "-c", "core.quotePath=false"}, args...)...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// gitStream runs a git command in the repository and passes its output to
+// consume as it is produced
+func (s *GitScanner) gitStream(ctx context.Context, consume func(io.Reader) error, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.repoPath, "-c", "core.quotePath=false"}, args...)...)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting git: %v", err)
+	}
+
+	consumeErr := consume(stdout)
+	if consumeErr != nil {
+		// Stop git instead of waiting for output nobody reads
+		cmd.Process.Kill()
+	}
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil && consumeErr == nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return consumeErr
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,320 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Databases MongoDB keeps for itself, skipped unless named in the URL
+var mongoSystemDatabases = map[string]bool{"admin": true, "local": true, "config": true}
+
+// isMongoURL reports whether a database URL points to MongoDB
+func isMongoURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "mongodb://") || strings.HasPrefix(dbURL, "mongodb+srv://")
+}
+
+// scanMongo scans the documents of each selected collection. Databases are
+// selected like schemas and collections like tables: the database in the URL,
+// or every database matching the schema filters, or every database but the
+// system ones. Findings are located as mongodb://db/collection/_id/field.path.
+func (s *DBScanner) scanMongo(ctx context.Context, dbURL string) ([]model.Violation, error) {
+	switch s.config.Mode {
+	case "", DBModeSample, DBModeFull:
+	case DBModeIncremental:
+		return nil, fmt.Errorf("incremental scans are not supported for MongoDB, use sample or full mode")
+	default:
+		return nil, fmt.Errorf("unsupported database scan mode: %s", s.config.Mode)
+	}
+
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %v", err)
+	}
+	s.database = u.Redacted()
+	s.name = u.Host
+
+	opts := options.Client().ApplyURI(dbURL).SetMaxPoolSize(uint64(s.config.workers()))
+	client, err := mongo.Connect(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	pingCtx, cancel := s.config.queryContext(ctx)
+	err = client.Ping(pingCtx, nil)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	collections, err := s.selectCollections(ctx, client, strings.Trim(u.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Scan the collections concurrently, keeping findings in collection order
+	results := make([][]model.Violation, len(collections))
+	errs := s.config.forEach(ctx, len(collections), func(i int) error {
+		var err error
+		results[i], err = s.scanCollection(ctx, client, collections[i])
+		return err
+	})
+
+	var violations []model.Violation
+	for i, collection := range collections {
+		if errs[i] != nil {
+			fmt.Printf("Error scanning collection %s: %v\n", collection, errs[i])
+		}
+		violations = append(violations, results[i]...)
+	}
+	return violations, ctx.Err()
+}
+
+// selectCollections lists the collections selected by the database and
+// collection filters, as tables of the database they belong to
+func (s *DBScanner) selectCollections(ctx context.Context, client *mongo.Client, database string) ([]Table, error) {
+	ctx, cancel := s.config.queryContext(ctx)
+	defer cancel()
+
+	var databases []string
+	if database != "" && len(s.config.Schemas) == 0 {
+		databases = []string{database}
+	} else {
+		names, err := client.ListDatabaseNames(ctx, bson.D{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list databases: %v", err)
+		}
+		for _, name := range names {
+			if len(s.config.Schemas) == 0 && mongoSystemDatabases[name] ||
+				len(s.config.Schemas) > 0 && !matchGlobs(s.config.Schemas, name) {
+				continue
+			}
+			databases = append(databases, name)
+		}
+	}
+
+	var collections []Table
+	for _, database := range databases {
+		// Views would scan their source collections again
+		names, err := client.Database(database).ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections of %s: %v", database, err)
+		}
+		for _, name := range names {
+			collection := Table{Schema: database, Name: name}
+			if strings.HasPrefix(name, "system.") || !s.config.selectTable(nil, collection) {
+				continue
+			}
+			collections = append(collections, collection)
+		}
+	}
+	return collections, nil
+}
+
+// scanCollection scans a sample of the documents of a collection, or all of
+// them in _id order in full mode
+func (s *DBScanner) scanCollection(ctx context.Context, client *mongo.Client, table Table) ([]model.Violation, error) {
+	collection := client.Database(table.Schema).Collection(table.Name)
+
+	sampleSize := 0
+	if s.config.Mode != DBModeFull && s.config.SampleSize > 0 {
+		// The fields of the first document stand in for the columns of a table
+		queryCtx, cancel := s.config.queryContext(ctx)
+		var first bson.D
+		err := collection.FindOne(queryCtx, bson.D{}).Decode(&first)
+		cancel()
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %v", err)
+		}
+		columns := make([]Column, len(first))
+		for i, field := range first {
+			columns[i] = Column{Name: field.Key}
+		}
+		sampleSize = s.config.sampleSize(columns)
+	}
+
+	batchSize := s.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultDBBatchSize
+	}
+
+	queryCtx, cancel := s.config.queryContext(ctx)
+	var cursor *mongo.Cursor
+	var err error
+	if sampleSize > 0 {
+		pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sampleSize}}}}}
+		cursor, err = collection.Aggregate(queryCtx, pipeline, options.Aggregate().SetBatchSize(int32(batchSize)))
+	} else {
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(int32(batchSize))
+		cursor, err = collection.Find(queryCtx, bson.D{}, opts)
+	}
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var violations []model.Violation
+	for {
+		// Each batch past the first is another query, with its own timeout
+		queryCtx, cancel := s.config.queryContext(ctx)
+		more := cursor.Next(queryCtx)
+		cancel()
+		if !more {
+			break
+		}
+
+		var document bson.D
+		if err := cursor.Decode(&document); err != nil {
+			return violations, fmt.Errorf("failed to decode document: %v", err)
+		}
+		violations = append(violations, s.scanDocument(table, document)...)
+	}
+	return violations, cursor.Err()
+}
+
+// scanDocument scans each value of a document along with its field path,
+// reporting the first match of each value
+func (s *DBScanner) scanDocument(table Table, document bson.D) []model.Violation {
+	var id interface{}
+	fields := make(map[string]interface{}, len(document))
+	for _, field := range document {
+		if field.Key == "_id" {
+			id = field.Value
+			continue
+		}
+		if !s.config.excludeColumn(table, field.Key) {
+			fields[field.Key] = normalizeBSON(field.Value)
+		}
+	}
+
+	var leaves []pathValue
+	extractPathValues(&leaves, "", fields)
+
+	var violations []model.Violation
+	for _, leaf := range leaves {
+		for _, pattern := range s.patterns {
+			if pattern.CompiledRegex == nil {
+				fmt.Printf("Warning: Skipping rule %s - regex not compiled\n", pattern.Name)
+				continue
+			}
+			match := pattern.CompiledRegex.FindString(leaf.Value)
+			if match == "" {
+				continue
+			}
+
+			v := model.Violation{
+				Rule:     pattern,
+				Match:    match,
+				Location: mongoLocation(table, id, leaf.Path),
+				Context:  fmt.Sprintf("Field '%s' contains sensitive data: %s", leaf.Path, getMatchContext(leaf.Value, match)),
+			}
+			if s.config.EmitSelect {
+				v.Query = fmt.Sprintf("db.getSiblingDB(%s).getCollection(%s).find({_id: %s})",
+					strconv.Quote(table.Schema), strconv.Quote(table.Name), mongoIDLiteral(id))
+			}
+			violations = append(violations, v)
+			break
+		}
+	}
+	return violations
+}
+
+// mongoLocation locates a value as mongodb://db/collection/_id/field.path
+func mongoLocation(table Table, id interface{}, path string) string {
+	return fmt.Sprintf("mongodb://%s/%s/%s/%s", table.Schema, table.Name, url.PathEscape(mongoIDString(id)), path)
+}
+
+// mongoIDString formats a document _id for locations
+func mongoIDString(id interface{}) string {
+	switch id := id.(type) {
+	case bson.ObjectID:
+		return id.Hex()
+	case string:
+		return id
+	case nil:
+		return ""
+	}
+	return mongoExtJSON(id)
+}
+
+// mongoIDLiteral formats a document _id as a mongosh expression
+func mongoIDLiteral(id interface{}) string {
+	switch id := id.(type) {
+	case bson.ObjectID:
+		return fmt.Sprintf("ObjectId(%q)", id.Hex())
+	case string:
+		return strconv.Quote(id)
+	}
+	return mongoExtJSON(id)
+}
+
+// mongoExtJSON formats a value as relaxed Extended JSON, so numbers stay
+// plain and documents read as JSON
+func mongoExtJSON(value interface{}) string {
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(string(data), `{"v":`), "}")
+}
+
+// normalizeBSON converts a decoded BSON value to the maps, slices, strings,
+// floats and bools extractPathValues walks. Values that cannot hold secrets,
+// such as dates and non-text binary data, are dropped.
+func normalizeBSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		document := make(map[string]interface{}, len(v))
+		for _, field := range v {
+			document[field.Key] = normalizeBSON(field.Value)
+		}
+		return document
+	case bson.M:
+		document := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			document[key] = normalizeBSON(field)
+		}
+		return document
+	case bson.A:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeBSON(item)
+		}
+		return items
+	case string, float64, bool:
+		return v
+	case int32, int64:
+		// Kept as text, so long numbers such as card numbers keep every digit
+		return fmt.Sprint(v)
+	case bson.Decimal128:
+		return v.String()
+	case bson.ObjectID:
+		return v.Hex()
+	case bson.Binary:
+		if utf8.Valid(v.Data) {
+			return string(v.Data)
+		}
+	case bson.Symbol:
+		return string(v)
+	case bson.JavaScript:
+		return string(v)
+	case bson.CodeWithScope:
+		return string(v.Code)
+	case bson.Regex:
+		return v.Pattern
+	}
+	return nil
+}
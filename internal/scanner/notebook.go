@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Cell types reported for notebook segments
+const (
+	notebookCellCode     = "code"
+	notebookCellMarkdown = "markdown"
+	notebookCellRaw      = "raw"
+	notebookCellOutput   = "output"
+)
+
+// notebook is the subset of the Jupyter notebook (nbformat 4) schema we scan
+type notebook struct {
+	Cells []notebookCell `json:"cells"`
+}
+
+type notebookCell struct {
+	CellType string           `json:"cell_type"`
+	Source   notebookText     `json:"source"`
+	Outputs  []notebookOutput `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       notebookText               `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"`
+	Metadata   map[string]interface{}     `json:"metadata"`
+	EName      string                     `json:"ename"`
+	EValue     string                     `json:"evalue"`
+	Traceback  []string                   `json:"traceback"`
+}
+
+// notebookText handles multiline strings, which nbformat allows to be stored
+// either as a single string or as a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = notebookText(s)
+		return nil
+	}
+
+	var parts []string
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	*t = notebookText(strings.Join(parts, ""))
+	return nil
+}
+
+// extractFromNotebook extracts text from a Jupyter notebook, keeping track of
+// the cell each line came from. Markdown and raw cells are reported with their
+// own type and any other cell as code, everything a cell printed or displayed is
+// reported as output.
+// Base64 image payloads are skipped, but their metadata is still scanned.
+func extractFromNotebook(content []byte) ([]Segment, error) {
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, fmt.Errorf("error parsing notebook: %v", err)
+	}
+
+	var segments []Segment
+	for i, cell := range nb.Cells {
+		cellType := cell.CellType
+		if cellType != notebookCellMarkdown && cellType != notebookCellRaw {
+			cellType = notebookCellCode
+		}
+		segments = appendCellLines(segments, i, cellType, []string{string(cell.Source)})
+
+		var outputs []string
+		for _, output := range cell.Outputs {
+			outputs = append(outputs, notebookOutputText(output)...)
+		}
+		segments = appendCellLines(segments, i, notebookCellOutput, outputs)
+	}
+
+	return segments, nil
+}
+
+// appendCellLines splits texts into lines and appends them as segments of the
+// given cell, numbering lines continuously across texts
+func appendCellLines(segments []Segment, index int, cellType string, texts []string) []Segment {
+	path := fmt.Sprintf("cells[%d]:%s", index, cellType)
+	lineNum := 0
+	for _, text := range texts {
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			lineNum++
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			segments = append(segments, Segment{Text: line, Path: path, LineNum: lineNum})
+		}
+	}
+	return segments
+}
+
+// notebookOutputText returns the scannable text of a single cell output
+func notebookOutputText(output notebookOutput) []string {
+	var texts []string
+
+	if output.Text != "" {
+		texts = append(texts, string(output.Text))
+	}
+	if output.EName != "" || output.EValue != "" {
+		texts = append(texts, output.EName+": "+output.EValue)
+	}
+	texts = append(texts, output.Traceback...)
+
+	// Sort mime types so output line numbers are stable between runs
+	mimeTypes := make([]string, 0, len(output.Data))
+	for mimeType := range output.Data {
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	sort.Strings(mimeTypes)
+
+	for _, mimeType := range mimeTypes {
+		// Images are stored as base64, there is nothing to find in the bytes
+		if strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml" {
+			continue
+		}
+
+		var text notebookText
+		if err := json.Unmarshal(output.Data[mimeType], &text); err == nil {
+			texts = append(texts, string(text))
+			continue
+		}
+
+		// Structured payloads such as application/json
+		var data interface{}
+		if err := json.Unmarshal(output.Data[mimeType], &data); err == nil {
+			extractValues(&texts, data)
+		}
+	}
+
+	extractValues(&texts, output.Metadata)
+
+	return texts
+}
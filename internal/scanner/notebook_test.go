@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractFromNotebook(t *testing.T) {
+	content := []byte(`{
+  "cells": [
+    {"cell_type": "markdown", "source": "# Setup\nUse your own key"},
+    {
+      "cell_type": "code",
+      "source": ["import os\n", "token = 'abc'\n"],
+      "outputs": [
+        {"output_type": "stream", "name": "stdout", "text": ["connected\n", "TOKEN=xyz\n"]},
+        {
+          "output_type": "display_data",
+          "data": {"image/png": "iVBORw0KGgoAAAANSUhEUg", "text/plain": "<Figure>"},
+          "metadata": {"image/png": {"alt": "api_key=123"}}
+        }
+      ]
+    },
+    {"cell_type": "raw", "source": "password: hunter2"}
+  ],
+  "nbformat": 4
+}`)
+
+	segments, err := extractFromNotebook(content)
+	assert.NoError(t, err)
+
+	assert.Contains(t, segments, Segment{Text: "Use your own key", Path: "cells[0]:markdown", LineNum: 2})
+	assert.Contains(t, segments, Segment{Text: "token = 'abc'", Path: "cells[1]:code", LineNum: 2})
+	assert.Contains(t, segments, Segment{Text: "TOKEN=xyz", Path: "cells[1]:output", LineNum: 2})
+	assert.Contains(t, segments, Segment{Text: "<Figure>", Path: "cells[1]:output", LineNum: 3})
+	assert.Contains(t, segments, Segment{Text: "api_key=123", Path: "cells[1]:output", LineNum: 4})
+	assert.Contains(t, segments, Segment{Text: "password: hunter2", Path: "cells[2]:raw", LineNum: 1})
+
+	for _, segment := range segments {
+		assert.NotContains(t, segment.Text, "iVBORw0KGgo")
+	}
+}
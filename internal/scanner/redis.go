@@ -0,0 +1,262 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisScanCount is the COUNT hint of each SCAN, and of the HSCAN,
+// SSCAN and ZSCAN reading large values
+const DefaultRedisScanCount = 100
+
+// isRedisURL reports whether a database URL points to Redis
+func isRedisURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "redis://") || strings.HasPrefix(dbURL, "rediss://")
+}
+
+// scanRedis scans the keys of a Redis database matching RedisMatch. Keys are
+// listed with SCAN, and hashes, sets and sorted sets read with HSCAN, SSCAN
+// and ZSCAN, so the server is never blocked the way KEYS or HGETALL on a
+// large key would. In sample mode the first SampleSize keys SCAN returns are
+// read, and at most SampleSize elements of each. Findings are located as
+// redis://db/key, with the hash field, list index or stream entry and field
+// appended for those types.
+func (s *DBScanner) scanRedis(ctx context.Context, dbURL string) ([]model.Violation, error) {
+	limit := 0
+	switch s.config.Mode {
+	case "", DBModeSample:
+		limit = s.config.SampleSize
+	case DBModeFull:
+	case DBModeIncremental:
+		return nil, fmt.Errorf("incremental scans are not supported for Redis, use sample or full mode")
+	default:
+		return nil, fmt.Errorf("unsupported database scan mode: %s", s.config.Mode)
+	}
+
+	opts, err := redis.ParseURL(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %v", err)
+	}
+	opts.PoolSize = s.config.workers()
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	u, _ := url.Parse(dbURL)
+	s.database = u.Redacted()
+	s.name = strconv.Itoa(opts.DB)
+
+	pingCtx, cancel := s.config.queryContext(ctx)
+	err = client.Ping(pingCtx).Err()
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	match := s.config.RedisMatch
+	if match == "" {
+		match = "*"
+	}
+	count := s.redisCount()
+
+	var violations []model.Violation
+	var cursor uint64
+	scanned := 0
+	for {
+		queryCtx, cancel := s.config.queryContext(ctx)
+		keys, next, err := client.Scan(queryCtx, cursor, match, count).Result()
+		cancel()
+		if err != nil {
+			return violations, fmt.Errorf("failed to scan keys: %v", err)
+		}
+		if limit > 0 && scanned+len(keys) > limit {
+			keys = keys[:limit-scanned]
+		}
+		scanned += len(keys)
+
+		// Read the keys of each page concurrently, keeping findings in key order
+		results := make([][]model.Violation, len(keys))
+		errs := s.config.forEach(ctx, len(keys), func(i int) error {
+			var err error
+			results[i], err = s.scanRedisKey(ctx, client, keys[i], limit)
+			return err
+		})
+		for i, key := range keys {
+			if errs[i] != nil {
+				fmt.Printf("Error scanning key %s: %v\n", key, errs[i])
+			}
+			violations = append(violations, results[i]...)
+		}
+
+		cursor = next
+		if cursor == 0 || limit > 0 && scanned >= limit || ctx.Err() != nil {
+			break
+		}
+	}
+	return violations, ctx.Err()
+}
+
+// redisCount returns the COUNT hint of each SCAN
+func (s *DBScanner) redisCount() int64 {
+	if s.config.RedisCount <= 0 {
+		return DefaultRedisScanCount
+	}
+	return int64(s.config.RedisCount)
+}
+
+// scanRedisKey reads a key by its type and scans each value through the
+// format detector, reading at most limit elements unless limit is 0. Keys of
+// other types, such as those of modules, are skipped.
+func (s *DBScanner) scanRedisKey(ctx context.Context, client *redis.Client, key string, limit int) ([]model.Violation, error) {
+	var violations []model.Violation
+	scan := func(value, command string, fields ...string) {
+		location := redisLocation(s.name, key, fields...)
+		violations = append(violations, s.scanUnpacked(location, []byte(value), func(string) string {
+			return command
+		})...)
+	}
+
+	queryCtx, cancel := s.config.queryContext(ctx)
+	keyType, err := client.Type(queryCtx, key).Result()
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get type: %v", err)
+	}
+
+	maxSize := s.config.MaxValueSize
+	if maxSize <= 0 {
+		maxSize = DefaultDBMaxValueSize
+	}
+	quoted := strconv.Quote(key)
+	count := s.redisCount()
+
+	switch keyType {
+	case "string":
+		queryCtx, cancel := s.config.queryContext(ctx)
+		value, err := client.GetRange(queryCtx, key, 0, maxSize-1).Result()
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value: %v", err)
+		}
+		scan(value, "GET "+quoted)
+
+	case "hash":
+		err = s.redisScanElements(ctx, limit, 2, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+			return client.HScan(ctx, key, cursor, "*", count).Result()
+		}, func(pair []string) {
+			scan(pair[1], fmt.Sprintf("HGET %s %s", quoted, strconv.Quote(pair[0])), pair[0])
+		})
+
+	case "set":
+		err = s.redisScanElements(ctx, limit, 1, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+			return client.SScan(ctx, key, cursor, "*", count).Result()
+		}, func(member []string) {
+			scan(member[0], fmt.Sprintf("SISMEMBER %s %s", quoted, strconv.Quote(member[0])))
+		})
+
+	case "zset":
+		err = s.redisScanElements(ctx, limit, 2, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+			return client.ZScan(ctx, key, cursor, "*", count).Result()
+		}, func(pair []string) {
+			scan(pair[0], fmt.Sprintf("ZSCORE %s %s", quoted, strconv.Quote(pair[0])))
+		})
+
+	case "list":
+		for start := int64(0); limit == 0 || start < int64(limit); start += count {
+			stop := start + count - 1
+			if limit > 0 && stop >= int64(limit) {
+				stop = int64(limit) - 1
+			}
+			queryCtx, cancel := s.config.queryContext(ctx)
+			items, err := client.LRange(queryCtx, key, start, stop).Result()
+			cancel()
+			if err != nil {
+				return violations, fmt.Errorf("failed to read list: %v", err)
+			}
+			for i, item := range items {
+				index := strconv.FormatInt(start+int64(i), 10)
+				scan(item, fmt.Sprintf("LINDEX %s %s", quoted, index), index)
+			}
+			if int64(len(items)) < stop-start+1 {
+				break
+			}
+		}
+
+	case "stream":
+		read, after := 0, "-"
+		for limit == 0 || read < limit {
+			n := count
+			if limit > 0 && int64(limit-read) < n {
+				n = int64(limit - read)
+			}
+			queryCtx, cancel := s.config.queryContext(ctx)
+			entries, err := client.XRangeN(queryCtx, key, after, "+", n).Result()
+			cancel()
+			if err != nil {
+				return violations, fmt.Errorf("failed to read stream: %v", err)
+			}
+			for _, entry := range entries {
+				fields := make([]string, 0, len(entry.Values))
+				for field := range entry.Values {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+				for _, field := range fields {
+					scan(fmt.Sprint(entry.Values[field]), fmt.Sprintf("XRANGE %s %s %s", quoted, entry.ID, entry.ID),
+						entry.ID, field)
+				}
+				after = "(" + entry.ID
+			}
+			read += len(entries)
+			if int64(len(entries)) < n {
+				break
+			}
+		}
+	}
+	return violations, err
+}
+
+// redisScanElements reads the elements of a hash, set or sorted set with
+// HSCAN, SSCAN or ZSCAN, calling fn with each group of size values: a field
+// and value, a member, or a member and score. It stops after limit elements
+// unless limit is 0.
+func (s *DBScanner) redisScanElements(ctx context.Context, limit, size int, scan func(ctx context.Context, cursor uint64) ([]string, uint64, error), fn func(values []string)) error {
+	var cursor uint64
+	read := 0
+	for {
+		queryCtx, cancel := s.config.queryContext(ctx)
+		values, next, err := scan(queryCtx, cursor)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to read elements: %v", err)
+		}
+		for i := 0; i+size <= len(values); i += size {
+			if limit > 0 && read >= limit {
+				return nil
+			}
+			fn(values[i : i+size])
+			read++
+		}
+
+		cursor = next
+		if cursor == 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// redisLocation locates a value as redis://db/key, followed by the hash field,
+// list index, or stream entry ID and field for values of those types
+func redisLocation(db, key string, fields ...string) string {
+	location := fmt.Sprintf("redis://%s/%s", db, url.PathEscape(key))
+	for _, field := range fields {
+		location += "/" + url.PathEscape(field)
+	}
+	return location
+}
@@ -1,7 +1,6 @@
 package scanner
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"net/url"
@@ -91,34 +90,16 @@ func (s *S3Scanner) ScanBucket(s3URL string) ([]model.Violation, error) {
 				continue
 			}
 
-			// Scan object content
-			scanner := bufio.NewScanner(result.Body)
-			lineNum := 0
-			for scanner.Scan() {
-				lineNum++
-				line := scanner.Text()
-
-				// Check each pattern
-				for _, pattern := range s.patterns {
-					if pattern.CompiledRegex == nil {
-						fmt.Printf("Warning: Skipping rule %s - regex not compiled\n", pattern.Name)
-						continue
-					}
-					if pattern.CompiledRegex.MatchString(line) {
-						violations = append(violations, model.Violation{
-							Rule:     pattern,
-							Match:    line,
-							Location: fmt.Sprintf("s3://%s/%s", bucket, *obj.Key),
-							LineNum:  lineNum,
-							Context:  line,
-						})
-					}
-				}
-			}
+			// Scan object content, transcoded to UTF-8
+			decoded, encoding := newDecodingReader(result.Body)
+			location := fmt.Sprintf("s3://%s/%s", bucket, *obj.Key)
+			err = scanStream(decoded, s.patterns, encoding, location, func(v model.Violation) {
+				violations = append(violations, v)
+			})
 
 			result.Body.Close()
 
-			if err := scanner.Err(); err != nil {
+			if err != nil {
 				fmt.Printf("Error scanning object %s: %v\n", *obj.Key, err)
 			}
 		}
@@ -2,7 +2,10 @@ package scanner
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -17,6 +20,7 @@ type SecretScanner struct {
 	patterns    []model.Configuration
 	ignoreDirs  []string
 	verbose     bool
+	cache       *ScanCache
 }
 
 func NewSecretScanner() *SecretScanner {
@@ -79,6 +83,17 @@ func (s *SecretScanner) AddPattern(patterns ...model.Configuration) {
 	s.patterns = append(s.patterns, patterns...)
 }
 
+// EnableCache makes StartScan reuse findings cached in dir for files whose
+// content has not changed. It must be called after all patterns are added.
+func (s *SecretScanner) EnableCache(dir string) (*ScanCache, error) {
+	cache, err := NewScanCache(dir, s.patterns)
+	if err != nil {
+		return nil, err
+	}
+	s.cache = cache
+	return cache, nil
+}
+
 func (s *SecretScanner) shouldIgnore(path string) bool {
 	for _, dir := range s.ignoreDirs {
 		if strings.Contains(path, dir) {
@@ -100,7 +115,7 @@ func (s *SecretScanner) StartScan(path string) []model.Violation {
 
 	if !info.IsDir() {
 		// Single file scan
-		fileViolations := s.scanFile(path)
+		fileViolations := s.scanFileCached(path)
 		violations = append(violations, fileViolations...)
 		return violations
 	}
@@ -123,7 +138,7 @@ func (s *SecretScanner) StartScan(path string) []model.Violation {
 			return nil
 		}
 
-		fileViolations := s.scanFile(filePath)
+		fileViolations := s.scanFileCached(filePath)
 		violations = append(violations, fileViolations...)
 		return nil
 	})
@@ -135,51 +150,182 @@ func (s *SecretScanner) StartScan(path string) []model.Violation {
 	return violations
 }
 
-func (s *SecretScanner) scanFile(filePath string) []model.Violation {
-	var violations []model.Violation
+// scanFileCached scans a file, replaying cached findings instead when the
+// cache is enabled and the file content was scanned before
+func (s *SecretScanner) scanFileCached(filePath string) []model.Violation {
+	if s.cache == nil {
+		return s.scanFile(filePath)
+	}
+
+	key, err := fileKey(filePath)
+	if err != nil {
+		fmt.Printf("Error hashing file %s: %v\n", filePath, err)
+		return s.scanFile(filePath)
+	}
 
+	if violations, ok := s.cache.load(key, s.patterns, filePath); ok {
+		s.cache.Hits++
+		return violations
+	}
+	s.cache.Misses++
+
+	violations := s.scanFile(filePath)
+	if err := s.cache.store(key, s.patterns, filePath, violations); err != nil {
+		fmt.Printf("Warning: Error caching findings for %s: %v\n", filePath, err)
+	}
+	return violations
+}
+
+func (s *SecretScanner) scanFile(filePath string) []model.Violation {
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Printf("Error opening file %s: %v\n", filePath, err)
-		return violations
+		return nil
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		for _, pattern := range s.patterns {
-			if pattern.CompiledRegex != nil && pattern.CompiledRegex.MatchString(line) {
-				// Get context around the match
-				match := pattern.CompiledRegex.FindString(line)
-				context := getMatchContext(line, match)
-
-				violations = append(violations, model.Violation{
-					Rule:     pattern,
-					Match:    match,
-					Location: filePath,
-					LineNum:  lineNum,
-					Context:  context,
-				})
-
-				if s.verbose {
-					fmt.Printf("Found potential %s in %s:%d\n", pattern.Name, filePath, lineNum)
-				}
-			}
+	// SQLite databases are scanned table by table instead of as binary text
+	header := make([]byte, 4096)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+	if isSQLite(header) {
+		violations, err := scanSQLite(context.Background(), s.patterns, filePath, nil)
+		if err == nil {
+			return violations
+		}
+		fmt.Printf("Warning: Error reading SQLite database %s, scanning as binary: %v\n", filePath, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		fmt.Printf("Error reading file %s: %v\n", filePath, err)
+		return nil
+	}
+
+	// So are the rows of pg_dump and mysqldump output
+	if dialect, ok := sqlDumpDialect(header); ok {
+		violations, err := scanSQLDump(context.Background(), s.patterns, file, filePath, dialect, nil)
+		if err == nil {
+			return violations
+		}
+		fmt.Printf("Warning: Error reading SQL dump %s, scanning as text: %v\n", filePath, err)
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			fmt.Printf("Error reading file %s: %v\n", filePath, err)
+			return nil
+		}
+	}
+
+	return s.ScanReader(file, filePath)
+}
+
+// ScanReader scans content read from r, such as stdin or the output of another
+// tool. name is used to detect the format of the content and as the location
+// of findings.
+func (s *SecretScanner) ScanReader(r io.Reader, name string) []model.Violation {
+	var violations []model.Violation
+
+	// Content is transcoded to UTF-8 so patterns match UTF-16 and Latin-1
+	// files; columns are mapped back to the original encoding
+	decoded, encoding := newDecodingReader(r)
+	reader := bufio.NewReader(decoded)
+
+	// Structured formats are scanned through their extractor so findings keep
+	// their position in the document
+	header, _ := reader.Peek(512)
+	if _, ok := structuredFormat(name, header); ok {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", name, err)
+			return violations
 		}
+
+		segments, err := extractTextFromFile(name, bytes.NewReader(content))
+		if err == nil {
+			return s.scanSegments(name, segments)
+		}
+		fmt.Printf("Warning: Error processing %s, scanning as text: %v\n", name, err)
+		reader = bufio.NewReader(bytes.NewReader(content))
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Printf("Error scanning file %s: %v\n", filePath, err)
+	err := scanStream(reader, s.patterns, encoding, name, func(v model.Violation) {
+		violations = append(violations, v)
+
+		if s.verbose {
+			fmt.Printf("Found potential %s in %s:%d\n", v.Rule.Name, name, v.LineNum)
+		}
+	})
+	if err != nil {
+		fmt.Printf("Error scanning %s: %v\n", name, err)
 	}
 
 	return violations
 }
 
+// ScanDiff scans the lines added by a unified diff, such as the output of
+// git diff for a pull request. Findings are reported at their line in the
+// new version of each file.
+func (s *SecretScanner) ScanDiff(r io.Reader) ([]model.Violation, error) {
+	var violations []model.Violation
+
+	err := parseDiff(r, func(file diffFile) error {
+		matchDiffFile(s.patterns, file.Path, file, func(v model.Violation) {
+			violations = append(violations, v)
+
+			if s.verbose {
+				fmt.Printf("Found potential %s in %s:%d\n", v.Rule.Name, file.Path, v.LineNum)
+			}
+		})
+		return nil
+	})
+
+	return violations, err
+}
+
+// scanSegments checks text extracted from a structured file against all patterns
+func (s *SecretScanner) scanSegments(location string, segments []Segment) []model.Violation {
+	var violations []model.Violation
+
+	matchSegments(s.patterns, location, segments, func(v model.Violation) {
+		violations = append(violations, v)
+
+		if s.verbose {
+			fmt.Printf("Found potential %s in %s [%s]:%d\n", v.Rule.Name, location, v.Path, v.LineNum)
+		}
+	})
+
+	return violations
+}
+
+// matchSegments checks each segment against all patterns and reports the
+// first match of each pattern
+func matchSegments(patterns []model.Configuration, location string, segments []Segment, report func(model.Violation)) {
+	for _, segment := range segments {
+		for _, pattern := range patterns {
+			if pattern.CompiledRegex == nil {
+				continue
+			}
+
+			loc := pattern.CompiledRegex.FindStringIndex(segment.Text)
+			if loc == nil {
+				continue
+			}
+			match := segment.Text[loc[0]:loc[1]]
+
+			violation := model.Violation{
+				Rule:     pattern,
+				Match:    match,
+				Location: location,
+				Path:     segment.Path,
+				LineNum:  segment.LineNum,
+				Context:  getMatchContext(segment.Text, match),
+			}
+			if segment.Col > 0 {
+				violation.StartCol = segment.Col + loc[0]
+				violation.EndCol = segment.Col + loc[1]
+			}
+			report(violation)
+		}
+	}
+}
+
 // getMatchContext returns a snippet of text around the matched string
 func getMatchContext(line, match string) string {
 	const contextLength = 50 // characters before and after the match
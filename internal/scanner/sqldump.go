@@ -0,0 +1,1043 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+// Markers pg_dump and mysqldump start their plain-text output with
+var (
+	postgresDumpMarkers = []string{"-- PostgreSQL database dump", "SET statement_timeout = 0;"}
+	mysqlDumpMarkers    = []string{"-- MySQL dump", "-- MariaDB dump", "/*!40101 SET"}
+)
+
+// mysqlDumpDatabase finds the database named in the header of mysqldump
+// output, which has no USE statement when a single database is dumped
+var mysqlDumpDatabase = regexp.MustCompile(`(?m)^-- Host: .*Database: (\S+)`)
+
+// sqlDumpDialect detects pg_dump and mysqldump output from its first bytes
+func sqlDumpDialect(header []byte) (Dialect, bool) {
+	for _, marker := range postgresDumpMarkers {
+		if bytes.Contains(header, []byte(marker)) {
+			return postgresDialect{}, true
+		}
+	}
+	for _, marker := range mysqlDumpMarkers {
+		if bytes.Contains(header, []byte(marker)) {
+			return mysqlDialect{name: "mysql"}, true
+		}
+	}
+	return nil, false
+}
+
+// scanSQLDumpFile scans a dump file named by a sqldump:///path/dump.sql URL.
+// Files without a pg_dump or mysqldump header are read as MySQL when they
+// quote identifiers with backquotes and as PostgreSQL otherwise.
+func scanSQLDumpFile(ctx context.Context, patterns []model.Configuration, path string, config *DBConfig) ([]model.Violation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQL dump: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, _ := reader.Peek(4096)
+	dialect, ok := sqlDumpDialect(header)
+	if !ok {
+		dialect = Dialect(postgresDialect{})
+		if bytes.Contains(header, []byte("`")) {
+			dialect = mysqlDialect{name: "mysql"}
+		}
+	}
+	return scanSQLDump(ctx, patterns, reader, path, dialect, config)
+}
+
+// scanSQLDump scans the plain-text output of pg_dump or mysqldump without a
+// database. Rows of COPY ... FROM stdin blocks and INSERT statements are
+// mapped to their table and columns and scanned like the rows of a live
+// database, reading all rows without a config; sample mode keeps a random
+// sample of each table. Findings are located as dump.sql!/schema.table/column,
+// followed by ?pk=id:42 for tables with a primary key, at the line of the row.
+// Other statements are scanned as text.
+func scanSQLDump(ctx context.Context, patterns []model.Configuration, r io.Reader, path string, dialect Dialect, config *DBConfig) ([]model.Violation, error) {
+	if config == nil {
+		config = &DBConfig{Mode: DBModeFull}
+	}
+
+	s := NewDBScanner(config)
+	s.AddPattern(patterns...)
+	s.locate = func(table Table, column string, keys, key []string) string {
+		return fmt.Sprintf("%s!/%s/%s", path, table, column) + keyQuery(keys, key)
+	}
+
+	_, mysql := dialect.(mysqlDialect)
+	reader := bufio.NewReader(r)
+	p := &dumpParser{
+		ctx:     ctx,
+		s:       s,
+		dialect: dialect,
+		path:    path,
+		lex:     &dumpLexer{r: reader, line: 1, mysql: mysql},
+		schemas: config.Schemas,
+		tables:  make(map[string]*dumpTable),
+		keyed:   make(map[*dumpData]*dumpData),
+	}
+	if mysql {
+		header, _ := reader.Peek(4096)
+		if m := mysqlDumpDatabase.FindSubmatch(header); m != nil {
+			p.schema = string(m[1])
+		}
+	}
+
+	if err := p.parse(); err != nil {
+		return p.violations, fmt.Errorf("failed to read SQL dump: %v", err)
+	}
+	return p.violations, ctx.Err()
+}
+
+// dumpTable is what a dump declares about a table ahead of its rows
+type dumpTable struct {
+	table   Table
+	columns []Column
+	keys    []string
+
+	// Rows sampled in sample mode, scanned once the whole dump is read
+	sampleSize int
+	seen       int
+	sample     []dumpRow
+}
+
+// dumpData maps the values of rows in one COPY block or INSERT statement to
+// the scanned columns and primary key of their table
+type dumpData struct {
+	table    *dumpTable
+	names    []string // Columns listed with the values, nil for all in order
+	width    int
+	scan     *tableScan
+	index    []int // Position of the value of each scanned column
+	keyIndex []int // Position of the value of each primary key column
+}
+
+type dumpRow struct {
+	data   *dumpData
+	values [][]byte // nil for NULL
+	line   int
+}
+
+type dumpParser struct {
+	ctx     context.Context
+	s       *DBScanner
+	dialect Dialect
+	path    string
+	lex     *dumpLexer
+
+	schemas []string
+	schema  string // Current schema, set by USE statements in MySQL dumps
+	tables  map[string]*dumpTable
+	order   []*dumpTable // Tables in the order their rows appear
+
+	// pg_dump adds primary keys after the rows, so rows with findings in
+	// tables without a known primary key are scanned again at the end
+	pending []dumpRow
+	keyed   map[*dumpData]*dumpData // Data remapped once its primary key is known
+	done    bool
+
+	violations []model.Violation
+}
+
+// parse reads the dump statement by statement
+func (p *dumpParser) parse() error {
+	for p.ctx.Err() == nil {
+		p.lex.startCapture()
+		tok := p.lex.next()
+		if tok.kind == tokenEOF {
+			p.scanCaptured()
+			break
+		}
+
+		switch strings.ToUpper(tok.text) {
+		case "INSERT", "REPLACE":
+			p.lex.stopCapture()
+			p.parseInsert()
+		case "COPY":
+			p.lex.stopCapture()
+			p.parseCopy()
+		case "CREATE":
+			p.parseCreate()
+		case "ALTER":
+			p.parseAlter()
+		case "USE":
+			if name := p.lex.next(); name.kind == tokenWord || name.kind == tokenIdent {
+				p.schema = name.text
+			}
+			p.skipStatement()
+		default:
+			if tok.text != ";" {
+				p.skipStatement()
+			}
+		}
+		p.scanCaptured()
+	}
+
+	// Sampled rows are only known once every row was seen, and primary keys
+	// once every constraint was read
+	p.done = true
+	for _, row := range p.pending {
+		p.scanRow(row)
+	}
+	for _, table := range p.order {
+		for _, row := range table.sample {
+			p.scanRow(row)
+		}
+	}
+	return p.lex.err
+}
+
+// scanCaptured scans the text of the statement just read, unless it held rows
+func (p *dumpParser) scanCaptured() {
+	text, line := p.lex.captured()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	var segments []Segment
+	for i, part := range strings.Split(text, "\n") {
+		segments = append(segments, Segment{Text: part, LineNum: line + i})
+	}
+	matchSegments(p.s.patterns, p.path, segments, func(v model.Violation) {
+		p.violations = append(p.violations, v)
+	})
+}
+
+// skipStatement reads up to the end of the current statement
+func (p *dumpParser) skipStatement() {
+	for {
+		tok := p.lex.next()
+		if tok.kind == tokenEOF || tok.text == ";" {
+			return
+		}
+	}
+}
+
+// parseName reads a table name, qualified or not. Unqualified names are in
+// the current schema.
+func (p *dumpParser) parseName() (Table, bool) {
+	tok := p.lex.next()
+	if tok.kind != tokenWord && tok.kind != tokenIdent {
+		return Table{}, false
+	}
+	table := Table{Schema: p.schema, Name: tok.text}
+	if p.lex.peek().text == "." {
+		p.lex.next()
+		name := p.lex.next()
+		if name.kind != tokenWord && name.kind != tokenIdent {
+			return Table{}, false
+		}
+		table = Table{Schema: tok.text, Name: name.text}
+	}
+	return table, true
+}
+
+// parseIdentList reads a parenthesized list of column names
+func (p *dumpParser) parseIdentList() []string {
+	var names []string
+	if p.lex.next().text != "(" {
+		return nil
+	}
+	for {
+		tok := p.lex.next()
+		switch {
+		case tok.kind == tokenEOF || tok.text == ")":
+			return names
+		case tok.kind == tokenWord || tok.kind == tokenIdent:
+			names = append(names, tok.text)
+		}
+	}
+}
+
+// tableModifiers may come between CREATE and TABLE
+var tableModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "TEMP": true, "TEMPORARY": true,
+	"UNLOGGED": true, "GLOBAL": true, "LOCAL": true,
+}
+
+// tableConstraints start table constraints rather than column definitions.
+// mysqldump quotes every column name, so any keyword starts a constraint.
+var tableConstraints = map[string]bool{
+	"PRIMARY": true, "CONSTRAINT": true, "UNIQUE": true, "FOREIGN": true,
+	"CHECK": true, "EXCLUDE": true, "LIKE": true,
+}
+
+// parseCreate records the columns and primary key of CREATE TABLE statements
+func (p *dumpParser) parseCreate() {
+	tok := p.lex.next()
+	for tok.kind == tokenWord && tableModifiers[strings.ToUpper(tok.text)] {
+		tok = p.lex.next()
+	}
+	if tok.kind != tokenWord || !strings.EqualFold(tok.text, "TABLE") {
+		p.skipStatement()
+		return
+	}
+	if strings.EqualFold(p.lex.peek().text, "IF") {
+		p.lex.next() // IF
+		p.lex.next() // NOT
+		p.lex.next() // EXISTS
+	}
+	table, ok := p.parseName()
+	if !ok || p.lex.next().text != "(" {
+		p.skipStatement()
+		return
+	}
+
+	definition := &dumpTable{table: table}
+	for {
+		tok := p.lex.next()
+		if tok.kind == tokenEOF || tok.text == ")" {
+			break
+		}
+		word := strings.ToUpper(tok.text)
+		if tok.kind == tokenWord && (tableConstraints[word] || p.lex.mysql) {
+			if word == "CONSTRAINT" {
+				p.lex.next() // Constraint name
+				word = strings.ToUpper(p.lex.next().text)
+			}
+			if word == "PRIMARY" {
+				p.lex.next() // KEY
+				definition.keys = p.parseIdentList()
+			}
+		} else if tok.kind == tokenWord || tok.kind == tokenIdent {
+			column := Column{Name: tok.text}
+			if dataType := p.lex.peek(); dataType.kind == tokenWord {
+				column.DataType = strings.ToLower(dataType.text)
+			}
+			definition.columns = append(definition.columns, column)
+		}
+
+		// Skip to the next definition, noting inline primary keys
+		primary, last := p.skipDefinition()
+		if primary {
+			definition.keys = []string{tok.text}
+		}
+		if last {
+			break
+		}
+	}
+	p.tables[table.String()] = definition
+	p.skipStatement()
+}
+
+// parseAlter records primary keys added by ALTER TABLE ... ADD CONSTRAINT ...
+// PRIMARY KEY, as pg_dump writes them after the rows of the table
+func (p *dumpParser) parseAlter() {
+	if !strings.EqualFold(p.lex.next().text, "TABLE") {
+		p.skipStatement()
+		return
+	}
+	for next := strings.ToUpper(p.lex.peek().text); next == "ONLY" || next == "IF" || next == "EXISTS"; next = strings.ToUpper(p.lex.peek().text) {
+		p.lex.next()
+	}
+	table, ok := p.parseName()
+	if !ok {
+		p.skipStatement()
+		return
+	}
+
+	previous := ""
+	for {
+		tok := p.lex.next()
+		if tok.kind == tokenEOF || tok.text == ";" {
+			return
+		}
+		if tok.kind == tokenWord && strings.EqualFold(tok.text, "KEY") && strings.EqualFold(previous, "PRIMARY") {
+			definition, ok := p.tables[table.String()]
+			if !ok {
+				definition = &dumpTable{table: table}
+				p.tables[table.String()] = definition
+			}
+			definition.keys = p.parseIdentList()
+		}
+		previous = tok.text
+	}
+}
+
+// skipDefinition reads up to the comma or parenthesis ending a column
+// definition. It reports whether the definition declared the column as
+// primary key, and whether it was the last one.
+func (p *dumpParser) skipDefinition() (primary bool, last bool) {
+	depth := 0
+	previous := ""
+	for {
+		tok := p.lex.peek()
+		switch {
+		case tok.kind == tokenEOF || tok.text == ";":
+			return primary, true
+		case tok.text == "(":
+			depth++
+		case tok.text == ")" && depth == 0:
+			p.lex.next()
+			return primary, true
+		case tok.text == ")":
+			depth--
+		case tok.text == "," && depth == 0:
+			p.lex.next()
+			return primary, false
+		case tok.kind == tokenWord && strings.EqualFold(tok.text, "KEY") && strings.EqualFold(previous, "PRIMARY"):
+			primary = true
+		}
+		previous = tok.text
+		p.lex.next()
+	}
+}
+
+// tableFor returns the known definition of a table, selected by the filters,
+// or nil when the table is filtered out
+func (p *dumpParser) tableFor(table Table) *dumpTable {
+	if !p.s.config.selectTable(p.schemas, table) {
+		return nil
+	}
+	definition, ok := p.tables[table.String()]
+	if !ok {
+		definition = &dumpTable{table: table}
+		p.tables[table.String()] = definition
+	}
+	return definition
+}
+
+// dataFor maps values listed in the given columns, or in the order the table
+// declares its columns when none are listed, to the scanned columns
+func (p *dumpParser) dataFor(table *dumpTable, names []string, width int) *dumpData {
+	if names == nil {
+		for _, column := range table.columns {
+			names = append(names, column.Name)
+		}
+		// Values of tables the dump never declared are named by position
+		for i := len(names); i < width; i++ {
+			names = append(names, "column"+strconv.Itoa(i+1))
+		}
+	}
+
+	types := make(map[string]string)
+	for _, column := range table.columns {
+		types[column.Name] = column.DataType
+	}
+
+	data := &dumpData{table: table, names: names, width: width}
+	var columns []Column
+	for i, name := range names {
+		if p.s.config.excludeColumn(table.table, name) {
+			continue
+		}
+		columns = append(columns, Column{Name: name, DataType: types[name]})
+		data.index = append(data.index, i)
+	}
+
+	// Rows are located by primary key only when every key value is listed
+	keys := table.keys
+	for _, key := range keys {
+		index := -1
+		for i, name := range names {
+			if name == key {
+				index = i
+			}
+		}
+		if index == -1 {
+			keys, data.keyIndex = nil, nil
+			break
+		}
+		data.keyIndex = append(data.keyIndex, index)
+	}
+	data.scan = newTableScan(p.dialect, table.table, keys, columns, nil)
+	return data
+}
+
+// addRow scans a row, or keeps it in the table sample in sample mode
+func (p *dumpParser) addRow(row dumpRow) {
+	table := row.data.table
+	if table.seen == 0 {
+		p.order = append(p.order, table)
+		table.sampleSize = -1
+		if p.s.config.Mode == "" || p.s.config.Mode == DBModeSample {
+			table.sampleSize = p.s.config.sampleSize(table.columns)
+		}
+	}
+	table.seen++
+
+	if table.sampleSize <= 0 {
+		p.scanRow(row)
+		return
+	}
+	if len(table.sample) < table.sampleSize {
+		table.sample = append(table.sample, row)
+	} else if i := rand.Intn(table.seen); i < table.sampleSize {
+		table.sample[i] = row
+	}
+}
+
+// scanRow checks the scanned values of a row, reporting findings at the
+// line of the row
+func (p *dumpParser) scanRow(row dumpRow) {
+	data := row.data
+	if len(data.keyIndex) == 0 && len(data.table.keys) > 0 {
+		data = p.keyedData(data)
+	}
+	key := make([]string, len(data.keyIndex))
+	for i, index := range data.keyIndex {
+		if index < len(row.values) {
+			key[i] = string(row.values[index])
+		}
+	}
+
+	var violations []model.Violation
+	for i, column := range data.scan.columns {
+		if data.index[i] >= len(row.values) {
+			continue
+		}
+		value := row.values[data.index[i]]
+		if len(value) == 0 {
+			continue
+		}
+		if kindOfColumn(column) == columnBinary {
+			value = decodeByteaHex(value)
+		}
+		for _, v := range p.s.scanValue(data.scan, column, key, value) {
+			v.LineNum = row.line
+			violations = append(violations, v)
+		}
+	}
+
+	if len(violations) > 0 && len(data.keyIndex) == 0 && !p.done {
+		p.pending = append(p.pending, row)
+		return
+	}
+	p.violations = append(p.violations, violations...)
+}
+
+// keyedData maps rows to their primary key once it is known, for data mapped
+// before the dump declared it
+func (p *dumpParser) keyedData(data *dumpData) *dumpData {
+	if keyed, ok := p.keyed[data]; ok {
+		return keyed
+	}
+	keyed := p.dataFor(data.table, data.names, data.width)
+	p.keyed[data] = keyed
+	return keyed
+}
+
+// decodeByteaHex decodes bytea values in PostgreSQL hex output format
+func decodeByteaHex(value []byte) []byte {
+	if !bytes.HasPrefix(value, []byte(`\x`)) {
+		return value
+	}
+	decoded := make([]byte, hex.DecodedLen(len(value)-2))
+	if _, err := hex.Decode(decoded, value[2:]); err != nil {
+		return value
+	}
+	return decoded
+}
+
+// parseInsert reads the rows of INSERT INTO table [(columns)] VALUES (...), ...
+func (p *dumpParser) parseInsert() {
+	for {
+		tok := p.lex.next()
+		if tok.kind == tokenEOF || tok.text == ";" {
+			return
+		}
+		if tok.kind == tokenWord && strings.EqualFold(tok.text, "INTO") {
+			break
+		}
+	}
+	name, ok := p.parseName()
+	if !ok {
+		p.skipStatement()
+		return
+	}
+	var names []string
+	if p.lex.peek().text == "(" {
+		names = p.parseIdentList()
+	}
+	values := p.lex.next()
+	if strings.EqualFold(values.text, "OVERRIDING") {
+		// OVERRIDING SYSTEM VALUE, for identity columns
+		p.lex.next()
+		p.lex.next()
+		values = p.lex.next()
+	}
+	if !strings.EqualFold(values.text, "VALUES") && !strings.EqualFold(values.text, "VALUE") {
+		p.skipStatement()
+		return
+	}
+
+	table := p.tableFor(name)
+	var data *dumpData
+	for {
+		open := p.lex.next()
+		if open.text != "(" {
+			if open.text != ";" {
+				p.skipStatement()
+			}
+			return
+		}
+		values := p.parseTuple()
+		if table != nil {
+			if data == nil {
+				data = p.dataFor(table, names, len(values))
+			}
+			p.addRow(dumpRow{data: data, values: values, line: open.line})
+		}
+		if p.lex.peek().text == "," {
+			p.lex.next()
+		}
+	}
+}
+
+// parseTuple reads the values of a row up to its closing parenthesis. Values
+// are taken from their literals, so casts and function calls around them are
+// ignored.
+func (p *dumpParser) parseTuple() [][]byte {
+	var values [][]byte
+	var literals [][]byte
+	null := false
+	sign := ""
+	depth := 0
+
+	endValue := func() {
+		var value []byte
+		switch {
+		case null && len(literals) == 0:
+		case len(literals) == 1:
+			value = literals[0]
+		default:
+			value = bytes.Join(literals, []byte(" "))
+		}
+		values = append(values, value)
+		literals, null, sign = nil, false, ""
+	}
+
+	for {
+		tok := p.lex.next()
+		switch {
+		case tok.kind == tokenEOF:
+			return values
+		case tok.text == "(" || tok.text == "[":
+			depth++
+		case (tok.text == ")" || tok.text == "]") && depth > 0:
+			depth--
+		case tok.text == ")":
+			endValue()
+			return values
+		case tok.text == "," && depth == 0:
+			endValue()
+		case tok.text == "-":
+			sign = "-"
+		case tok.kind == tokenString:
+			literals = append(literals, []byte(tok.text))
+		case tok.kind == tokenWord:
+			word := tok.text
+			switch {
+			case strings.EqualFold(word, "NULL"):
+				null = true
+			case strings.HasPrefix(word, "_"):
+				// Character set introducers such as _binary precede a string
+			case strings.HasPrefix(word, "0x") || strings.HasPrefix(word, "0X"):
+				if decoded, err := hex.DecodeString(word[2:]); err == nil {
+					literals = append(literals, decoded)
+				}
+			case unicode.IsDigit(rune(word[0])):
+				literals = append(literals, []byte(sign+word))
+			case strings.EqualFold(word, "TRUE") || strings.EqualFold(word, "FALSE"):
+				literals = append(literals, []byte(strings.ToLower(word)))
+			}
+		}
+	}
+}
+
+// parseCopy reads the rows of COPY table (columns) FROM stdin, one per line
+// up to a line holding \. as written by pg_dump
+func (p *dumpParser) parseCopy() {
+	name, ok := p.parseName()
+	if !ok {
+		p.skipStatement()
+		return
+	}
+	var names []string
+	if p.lex.peek().text == "(" {
+		names = p.parseIdentList()
+	}
+	from, source := p.lex.next(), p.lex.next()
+	p.skipStatement()
+	if !strings.EqualFold(from.text, "FROM") || !strings.EqualFold(source.text, "stdin") {
+		return
+	}
+
+	// The data starts on the line after the statement
+	p.lex.readLine()
+
+	table := p.tableFor(name)
+	var data *dumpData
+	for {
+		line := p.lex.line
+		text, ok := p.lex.readLine()
+		if !ok || text == `\.` {
+			return
+		}
+		if table == nil {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		values := make([][]byte, len(fields))
+		for i, field := range fields {
+			if field != `\N` {
+				values[i] = unescapeCopy(field)
+			}
+		}
+		if data == nil {
+			data = p.dataFor(table, names, len(values))
+		}
+		p.addRow(dumpRow{data: data, values: values, line: line})
+	}
+}
+
+// unescapeCopy decodes a field of COPY text format
+func unescapeCopy(field string) []byte {
+	if !strings.Contains(field, `\`) {
+		return []byte(field)
+	}
+	var out []byte
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = field[i]; c {
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'v':
+			out = append(out, '\v')
+		case 'x':
+			end := i + 1
+			for end < len(field) && end < i+3 && isHexDigit(field[end]) {
+				end++
+			}
+			if end == i+1 {
+				out = append(out, c)
+				continue
+			}
+			n, _ := strconv.ParseUint(field[i+1:end], 16, 8)
+			out = append(out, byte(n))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(field[i:end], 8, 8)
+			out = append(out, byte(n))
+			i = end - 1
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// Kinds of tokens read from a dump
+const (
+	tokenEOF    = iota
+	tokenWord   // Keywords, unquoted names and numbers
+	tokenIdent  // Quoted names
+	tokenString // String literals, unescaped
+	tokenPunct  // Any other single character
+)
+
+type dumpToken struct {
+	kind int
+	text string
+	line int
+}
+
+// dumpLexer splits SQL into tokens, skipping whitespace and comments, and
+// keeps the raw text of the current statement while capturing
+type dumpLexer struct {
+	r     *bufio.Reader
+	line  int
+	mysql bool // Backslash escapes in strings, backquoted names, # comments
+	err   error
+
+	peeked *dumpToken
+
+	capturing   bool
+	capture     strings.Builder
+	captureLine int
+}
+
+func (l *dumpLexer) startCapture() {
+	l.capturing = true
+	l.capture.Reset()
+	l.captureLine = l.line
+	if l.peeked != nil {
+		l.capture.WriteString(l.peeked.text)
+	}
+}
+
+func (l *dumpLexer) stopCapture() {
+	l.capturing = false
+	l.capture.Reset()
+}
+
+// captured returns the text captured since startCapture and its first line
+func (l *dumpLexer) captured() (string, int) {
+	if !l.capturing {
+		return "", 0
+	}
+	return l.capture.String(), l.captureLine
+}
+
+func (l *dumpLexer) read() (rune, bool) {
+	c, _, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return 0, false
+	}
+	if c == '\n' {
+		l.line++
+	}
+	if l.capturing {
+		l.capture.WriteRune(c)
+	}
+	return c, true
+}
+
+// peekRune returns the next rune without reading it
+func (l *dumpLexer) peekRune() rune {
+	c, _, err := l.r.ReadRune()
+	if err != nil {
+		return 0
+	}
+	l.r.UnreadRune()
+	return c
+}
+
+// readLine reads the rest of the current line without its line break
+func (l *dumpLexer) readLine() (string, bool) {
+	text, err := l.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+	if text == "" && err != nil {
+		return "", false
+	}
+	if strings.HasSuffix(text, "\n") {
+		l.line++
+		text = text[:len(text)-1]
+	}
+	return text, true
+}
+
+func (l *dumpLexer) peek() dumpToken {
+	if l.peeked == nil {
+		tok := l.scan()
+		l.peeked = &tok
+	}
+	return *l.peeked
+}
+
+func (l *dumpLexer) next() dumpToken {
+	if l.peeked != nil {
+		tok := *l.peeked
+		l.peeked = nil
+		return tok
+	}
+	return l.scan()
+}
+
+func (l *dumpLexer) scan() dumpToken {
+	for {
+		c, ok := l.read()
+		if !ok {
+			return dumpToken{kind: tokenEOF, line: l.line}
+		}
+		line := l.line
+		switch {
+		case unicode.IsSpace(c):
+		case c == '-' && l.peekRune() == '-', c == '#' && l.mysql:
+			l.skipLine()
+		case c == '/' && l.peekRune() == '*':
+			l.skipComment()
+		case c == '\'':
+			return dumpToken{kind: tokenString, text: l.readQuoted('\'', l.mysql), line: line}
+		case c == '"' && l.mysql:
+			return dumpToken{kind: tokenString, text: l.readQuoted('"', true), line: line}
+		case c == '"', c == '`':
+			return dumpToken{kind: tokenIdent, text: l.readQuoted(c, false), line: line}
+		case c == '$' && !l.mysql:
+			return l.readDollar(line)
+		case isWordRune(c):
+			word := l.readWord(c)
+			if l.peekRune() == '\'' && len(word) == 1 {
+				// E'...' escape strings, X'...' hex and N'...' national strings
+				l.read()
+				switch strings.ToUpper(word) {
+				case "E":
+					return dumpToken{kind: tokenString, text: l.readQuoted('\'', true), line: line}
+				case "X":
+					text := l.readQuoted('\'', false)
+					if decoded, err := hex.DecodeString(text); err == nil {
+						text = string(decoded)
+					}
+					return dumpToken{kind: tokenString, text: text, line: line}
+				default:
+					return dumpToken{kind: tokenString, text: l.readQuoted('\'', l.mysql), line: line}
+				}
+			}
+			return dumpToken{kind: tokenWord, text: word, line: line}
+		default:
+			return dumpToken{kind: tokenPunct, text: string(c), line: line}
+		}
+	}
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func (l *dumpLexer) readWord(first rune) string {
+	word := []rune{first}
+	for {
+		c := l.peekRune()
+		// Numbers keep their decimal point
+		if !isWordRune(c) && c != '$' && !(c == '.' && unicode.IsDigit(first)) {
+			return string(word)
+		}
+		l.read()
+		word = append(word, c)
+	}
+}
+
+func (l *dumpLexer) skipLine() {
+	for {
+		c, ok := l.read()
+		if !ok || c == '\n' {
+			return
+		}
+	}
+}
+
+// skipComment skips a /* */ comment, including MySQL /*!40101 ... */
+// conditional comments, whose statements only set session options
+func (l *dumpLexer) skipComment() {
+	l.read()
+	previous := rune(0)
+	for {
+		c, ok := l.read()
+		if !ok || (previous == '*' && c == '/') {
+			return
+		}
+		previous = c
+	}
+}
+
+// readQuoted reads up to the closing quote, which is escaped by doubling it
+// and, with backslashes, by a backslash
+func (l *dumpLexer) readQuoted(quote rune, backslashes bool) string {
+	var text strings.Builder
+	for {
+		c, ok := l.read()
+		if !ok {
+			return text.String()
+		}
+		switch {
+		case c == quote && l.peekRune() == quote:
+			l.read()
+			text.WriteRune(quote)
+		case c == quote:
+			return text.String()
+		case c == '\\' && backslashes:
+			escaped, ok := l.read()
+			if !ok {
+				return text.String()
+			}
+			switch escaped {
+			case '0':
+				text.WriteByte(0)
+			case 'b':
+				text.WriteByte('\b')
+			case 'n':
+				text.WriteByte('\n')
+			case 'r':
+				text.WriteByte('\r')
+			case 't':
+				text.WriteByte('\t')
+			case 'Z':
+				text.WriteByte(0x1a)
+			case '%', '_':
+				// LIKE wildcards keep their backslash
+				text.WriteRune('\\')
+				text.WriteRune(escaped)
+			default:
+				text.WriteRune(escaped)
+			}
+		default:
+			text.WriteRune(c)
+		}
+	}
+}
+
+// readDollar reads a PostgreSQL $tag$ ... $tag$ string, such as a function
+// body, or a $ that starts no such string
+func (l *dumpLexer) readDollar(line int) dumpToken {
+	tag := "$"
+	for {
+		c := l.peekRune()
+		if c == '$' {
+			l.read()
+			tag += "$"
+			break
+		}
+		if !isWordRune(c) {
+			return dumpToken{kind: tokenPunct, text: tag, line: line}
+		}
+		l.read()
+		tag += string(c)
+	}
+
+	var text strings.Builder
+	for {
+		c, ok := l.read()
+		if !ok {
+			return dumpToken{kind: tokenString, text: text.String(), line: line}
+		}
+		text.WriteRune(c)
+		if c == '$' && strings.HasSuffix(text.String(), tag) {
+			body := text.String()
+			return dumpToken{kind: tokenString, text: body[:len(body)-len(tag)], line: line}
+		}
+	}
+}
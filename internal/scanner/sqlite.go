@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+
+	_ "modernc.org/sqlite" // SQLite driver
+)
+
+// sqliteHeader starts every SQLite 3 database file
+const sqliteHeader = "SQLite format 3\x00"
+
+// isSQLite reports whether a file header is that of a SQLite database
+func isSQLite(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(sqliteHeader))
+}
+
+// sqliteDSN returns a read-only connection string for a SQLite file
+func sqliteDSN(path string) string {
+	escaper := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+	return "file:" + escaper.Replace(path) + "?mode=ro"
+}
+
+// sqliteURLPath returns the file path of a sqlite:///path/app.db URL, or of
+// sqlite://./app.db for a relative path
+func sqliteURLPath(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+// scanSQLite scans a SQLite database file table by table, reading all rows
+// without a config. Findings are located as file.db!/table/column/rowid, or by
+// primary key values for WITHOUT ROWID tables.
+func scanSQLite(ctx context.Context, patterns []model.Configuration, path string, config *DBConfig) ([]model.Violation, error) {
+	if config == nil {
+		config = &DBConfig{Mode: DBModeFull}
+	}
+
+	dialect := sqliteDialect{}
+	db, err := sql.Open(dialect.DriverName(), sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	// Fail on files that only look like databases before reporting per table
+	if _, err := dialect.Tables(ctx, db); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+
+	s := NewDBScanner(config)
+	s.AddPattern(patterns...)
+	s.locate = func(table Table, column string, keys, key []string) string {
+		location := fmt.Sprintf("%s!/%s/%s", path, table.Name, column)
+		if len(key) > 0 {
+			location = fmt.Sprintf("%s/%s", location, strings.Join(key, ","))
+		}
+		return location
+	}
+
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		absolute = path
+	}
+	return s.scanDB(ctx, db, dialect, "sqlite://"+filepath.ToSlash(absolute))
+}
@@ -0,0 +1,189 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+)
+
+const (
+	// streamChunkSize is how much content is matched against patterns at once
+	streamChunkSize = 1 << 20 // 1MB
+
+	// streamOverlap is how much of a chunk is carried over into the next one,
+	// so matches up to this length are found even when they cross a boundary
+	streamOverlap = 4 << 10 // 4KB
+)
+
+// scanStream matches patterns against the UTF-8 content of r in fixed-size
+// chunks, so it runs in constant memory regardless of file or line length.
+// Chunks overlap so matches crossing a chunk boundary are not lost. Line and
+// column numbers are tracked across chunks, with columns counted in the
+// code units of encoding, the original encoding of the content.
+func scanStream(r io.Reader, patterns []model.Configuration, encoding TextEncoding, location string, report func(model.Violation)) error {
+	buf := make([]byte, 0, streamChunkSize+streamOverlap)
+	lineNum := 1 // Line of buf[0]
+	col := 0     // Columns between the start of that line and buf[0]
+
+	// End of the last match reported for each pattern, so the tail of a match
+	// that crossed into the overlap is not reported again
+	reportedUntil := make([]int, len(patterns))
+
+	for {
+		n, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return err
+		}
+
+		// Matches starting in the overlap may continue past the end of the
+		// buffer, so they are left for the next chunk
+		limit := len(buf)
+		if !eof {
+			limit = runeStart(buf, len(buf)-streamOverlap)
+		}
+
+		// Patterns are matched line by line, as classes like [^@]+ and \s*
+		// must not match across lines and ^ and $ anchor to lines
+		lines := streamLines(buf, limit)
+
+		for i, pattern := range patterns {
+			if pattern.CompiledRegex == nil {
+				continue
+			}
+
+			for _, loc := range streamMatches(pattern.CompiledRegex, buf, lines) {
+				if loc[0] >= limit {
+					break
+				}
+				if loc[0] < reportedUntil[i] {
+					continue
+				}
+				reportedUntil[i] = loc[1]
+
+				matchLine, startCol := streamPosition(buf, loc[0], lineNum, col, encoding)
+				_, endCol := streamPosition(buf, loc[1], lineNum, col, encoding)
+
+				report(model.Violation{
+					Rule:     pattern,
+					Match:    string(buf[loc[0]:loc[1]]),
+					Location: location,
+					LineNum:  matchLine,
+					StartCol: startCol,
+					EndCol:   endCol,
+					Context:  getStreamContext(buf, loc[0], loc[1], col > 0),
+				})
+			}
+		}
+
+		if eof {
+			return nil
+		}
+
+		// Advance past everything before the overlap and keep the rest
+		lineNum, col = streamAdvance(buf[:limit], lineNum, col, encoding)
+		buf = buf[:copy(buf, buf[limit:])]
+		for i := range reportedUntil {
+			reportedUntil[i] -= limit
+		}
+	}
+}
+
+// streamLines returns the start and end offsets of the lines of buf starting
+// before limit, without their newline. The first line may have started in an
+// earlier chunk, and the last may continue in the next one.
+func streamLines(buf []byte, limit int) [][2]int {
+	var lines [][2]int
+	for start := 0; start < limit || start == 0; {
+		end := bytes.IndexByte(buf[start:], '\n')
+		if end == -1 {
+			lines = append(lines, [2]int{start, len(buf)})
+			break
+		}
+		lines = append(lines, [2]int{start, start + end})
+		start += end + 1
+	}
+	return lines
+}
+
+// streamMatches returns the offsets in buf of the matches of re within each
+// of lines, in order
+func streamMatches(re *regexp.Regexp, buf []byte, lines [][2]int) [][]int {
+	var matches [][]int
+	for _, line := range lines {
+		for _, loc := range re.FindAllIndex(buf[line[0]:line[1]], -1) {
+			matches = append(matches, []int{line[0] + loc[0], line[0] + loc[1]})
+		}
+	}
+	return matches
+}
+
+// streamPosition returns the 1-based line and column of offset in buf, given
+// the line and column buf starts at
+func streamPosition(buf []byte, offset, lineNum, col int, encoding TextEncoding) (int, int) {
+	lineStart := bytes.LastIndexByte(buf[:offset], '\n') + 1
+	if lineStart == 0 {
+		return lineNum, col + encoding.columns(string(buf[:offset])) + 1
+	}
+	return lineNum + bytes.Count(buf[:lineStart], []byte("\n")), encoding.columns(string(buf[lineStart:offset])) + 1
+}
+
+// streamAdvance returns the line and column following consumed, given the
+// line and column it starts at
+func streamAdvance(consumed []byte, lineNum, col int, encoding TextEncoding) (int, int) {
+	lastNewline := bytes.LastIndexByte(consumed, '\n')
+	if lastNewline == -1 {
+		return lineNum, col + encoding.columns(string(consumed))
+	}
+	return lineNum + bytes.Count(consumed, []byte("\n")), encoding.columns(string(consumed[lastNewline+1:]))
+}
+
+// runeStart moves offset back to the start of the UTF-8 sequence it is in
+func runeStart(buf []byte, offset int) int {
+	for i := 0; i < utf8.UTFMax && offset > 0 && offset < len(buf) && !utf8.RuneStart(buf[offset]); i++ {
+		offset--
+	}
+	return offset
+}
+
+// getStreamContext returns a snippet of the line around buf[start:end].
+// lineContinues reports whether the line buf starts on began in an earlier chunk.
+func getStreamContext(buf []byte, start, end int, lineContinues bool) string {
+	const contextLength = 50 // characters before and after the match
+
+	lineStart := bytes.LastIndexByte(buf[:start], '\n') + 1
+	lineEnd := len(buf)
+	if i := bytes.IndexByte(buf[end:], '\n'); i != -1 {
+		lineEnd = end + i
+	}
+
+	contextStart := start - contextLength
+	if contextStart < lineStart {
+		contextStart = lineStart
+	}
+	contextStart = runeStart(buf, contextStart)
+	contextEnd := end + contextLength
+	if contextEnd > lineEnd {
+		contextEnd = lineEnd
+	}
+	contextEnd = runeStart(buf, contextEnd)
+	if contextEnd < end {
+		contextEnd = end
+	}
+
+	// Add ellipsis if context is truncated
+	prefix := ""
+	if contextStart > lineStart || (lineStart == 0 && lineContinues) {
+		prefix = "..."
+	}
+	suffix := ""
+	if contextEnd < lineEnd {
+		suffix = "..."
+	}
+
+	return prefix + string(buf[contextStart:contextEnd]) + suffix
+}
@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/adaptive-scale/blacklight/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanStream(t *testing.T) {
+	patterns := []model.Configuration{
+		{Name: "GitHub Token", CompiledRegex: regexp.MustCompile(`ghp_[0-9a-zA-Z]{36}`)},
+	}
+	token := "ghp_" + strings.Repeat("a", 36)
+
+	// A minified single line far longer than a chunk, with a token straddling
+	// the first chunk boundary, followed by a short line
+	boundary := streamChunkSize - streamOverlap
+	longLine := strings.Repeat("x", boundary-10) + token + strings.Repeat("y", streamChunkSize)
+	content := "first line\n" + longLine + "\nkey " + token + "\n"
+
+	var violations []model.Violation
+	err := scanStream(strings.NewReader(content), patterns, EncodingUTF8, "app.min.js", func(v model.Violation) {
+		violations = append(violations, v)
+	})
+	assert.NoError(t, err)
+
+	if assert.Len(t, violations, 2) {
+		assert.Equal(t, token, violations[0].Match)
+		assert.Equal(t, 2, violations[0].LineNum)
+		assert.Equal(t, boundary-9, violations[0].StartCol)
+		assert.Equal(t, boundary-9+len(token), violations[0].EndCol)
+		assert.True(t, strings.HasPrefix(violations[0].Context, "..."))
+
+		assert.Equal(t, 3, violations[1].LineNum)
+		assert.Equal(t, 5, violations[1].StartCol)
+		assert.Equal(t, "key "+token, violations[1].Context)
+	}
+}
+
+func TestScanStreamMatchesLines(t *testing.T) {
+	patterns := []model.Configuration{
+		{Name: "Password in URL", CompiledRegex: regexp.MustCompile(`(?i)://[^:]+:[^@]+@`)},
+		{Name: "Anchored", CompiledRegex: regexp.MustCompile(`^name: value$`)},
+	}
+
+	// Negated classes must not match across lines, and anchors match lines
+	content := "see http://example.com/docs\nname: value\ncontact admin@example.com"
+	var violations []model.Violation
+	err := scanStream(strings.NewReader(content), patterns, EncodingUTF8, "notes.txt", func(v model.Violation) {
+		violations = append(violations, v)
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "Anchored", violations[0].Rule.Name)
+		assert.Equal(t, 2, violations[0].LineNum)
+	}
+}
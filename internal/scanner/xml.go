@@ -0,0 +1,194 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlKeyAttributes are attributes that name the value held by the same
+// element, as in web.config <add key="..." value="..."/> and Spring
+// <property name="..." value="..."/>
+var xmlKeyAttributes = []string{"key", "name"}
+
+// xmlValueAttributes are attributes whose value is reported together with
+// the element's key attribute
+var xmlValueAttributes = []string{"value", "connectionString"}
+
+// mavenCredentialParents are the settings.xml elements whose children hold
+// credentials (e.g. <server><password>...</password></server>)
+var mavenCredentialParents = []string{"server", "proxy"}
+
+// xmlElement tracks an open element while walking the token stream
+type xmlElement struct {
+	name string
+	path string
+	key  string // Value of the element's key attribute, if any
+}
+
+// extractFromXML extracts text content, attribute values and comments from
+// XML, reporting each with an XPath-like element path and its line number.
+//
+// Values that are named by a sibling attribute or a parent element are
+// reported as "name=value" so key-based rules can match them: web.config
+// appSettings and connectionStrings, Spring <property> elements and Maven
+// settings.xml server and proxy credentials.
+func extractFromXML(content []byte) ([]Segment, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		// Content has already been transcoded to UTF-8
+		return input, nil
+	}
+
+	var segments []Segment
+	var stack []*xmlElement
+
+	for {
+		// Position of the start of the next token
+		line, _ := decoder.InputPos()
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing XML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := "/"
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].path + "/"
+			}
+
+			elem := &xmlElement{name: t.Name.Local, path: parent + t.Name.Local}
+			for _, attr := range t.Attr {
+				for _, keyAttr := range xmlKeyAttributes {
+					if attr.Name.Local == keyAttr && elem.key == "" {
+						elem.key = attr.Value
+						elem.path = fmt.Sprintf("%s[@%s=%s]", elem.path, keyAttr, xpathLiteral(attr.Value))
+					}
+				}
+			}
+			stack = append(stack, elem)
+
+			for _, attr := range t.Attr {
+				if strings.TrimSpace(attr.Value) == "" {
+					continue
+				}
+				text := attr.Value
+				if elem.key != "" && containsString(xmlValueAttributes, attr.Name.Local) {
+					text = elem.key + "=" + attr.Value
+				}
+				segments = append(segments, Segment{
+					Text:    text,
+					Path:    elem.path + "/@" + attr.Name.Local,
+					LineNum: line,
+				})
+			}
+
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			elem := stack[len(stack)-1]
+			key := xmlTextKey(stack)
+			for i, text := range strings.Split(string(t), "\n") {
+				if strings.TrimSpace(text) == "" {
+					continue
+				}
+				text = strings.TrimSpace(text)
+				if key != "" {
+					text = key + "=" + text
+				}
+				segments = append(segments, Segment{
+					Text:    text,
+					Path:    elem.path,
+					LineNum: line + i,
+				})
+			}
+
+		case xml.Comment:
+			path := "/comment()"
+			if len(stack) > 0 {
+				path = stack[len(stack)-1].path + path
+			}
+			for i, text := range strings.Split(string(t), "\n") {
+				if strings.TrimSpace(text) == "" {
+					continue
+				}
+				segments = append(segments, Segment{
+					Text:    strings.TrimSpace(text),
+					Path:    path,
+					LineNum: line + i,
+				})
+			}
+		}
+	}
+
+	return segments, nil
+}
+
+// xmlTextKey returns the name text inside the innermost element should be
+// reported under, or "" when the text is not a named value
+func xmlTextKey(stack []*xmlElement) string {
+	elem := stack[len(stack)-1]
+
+	// Spring: <property name="password"><value>secret</value></property>
+	if len(stack) > 1 && elem.name == "value" && stack[len(stack)-2].key != "" {
+		return stack[len(stack)-2].key
+	}
+
+	// Maven settings.xml: <server><password>secret</password></server>
+	if len(stack) > 2 && stack[0].name == "settings" &&
+		containsString(mavenCredentialParents, stack[len(stack)-2].name) {
+		return elem.name
+	}
+
+	return ""
+}
+
+// xpathLiteral quotes s as an XPath string literal. XPath has no escapes, so
+// values containing a single quote are put in double quotes, and values
+// containing both are built with concat().
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	return "concat('" + strings.ReplaceAll(s, "'", `', "'", '`) + "')"
+}
+
+// looksLikeXML reports whether content starts with markup rather than
+// key=value pairs
+func looksLikeXML(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(content, "\xef\xbb\xbf \t\r\n"), []byte("<"))
+}
+
+// hasXMLDeclaration reports whether content starts with an XML declaration
+func hasXMLDeclaration(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(content, "\xef\xbb\xbf \t\r\n"), []byte("<?xml"))
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractFromXML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []Segment
+	}{
+		{
+			name: "web.config appSettings and connection strings",
+			content: `<?xml version="1.0"?>
+<configuration>
+  <appSettings>
+    <add key="ApiKey" value="abc&amp;123"/>
+  </appSettings>
+  <connectionStrings>
+    <add name="Main" connectionString="Server=db;Password=hunter2"/>
+  </connectionStrings>
+</configuration>`,
+			expected: []Segment{
+				{Text: "ApiKey=abc&123", Path: "/configuration/appSettings/add[@key='ApiKey']/@value", LineNum: 4},
+				{Text: "Main=Server=db;Password=hunter2", Path: "/configuration/connectionStrings/add[@name='Main']/@connectionString", LineNum: 7},
+			},
+		},
+		{
+			name: "maven settings.xml server credentials",
+			content: `<settings>
+  <servers>
+    <server>
+      <id>nexus</id>
+      <password>s3cr3t</password>
+    </server>
+  </servers>
+</settings>`,
+			expected: []Segment{
+				{Text: "id=nexus", Path: "/settings/servers/server/id", LineNum: 4},
+				{Text: "password=s3cr3t", Path: "/settings/servers/server/password", LineNum: 5},
+			},
+		},
+		{
+			name: "spring property values and CDATA",
+			content: `<beans>
+  <bean id="ds">
+    <property name="password" value="p4ss"/>
+    <property name="token"><value>t0ken</value></property>
+    <description><![CDATA[first
+second <raw>]]></description>
+  </bean>
+</beans>`,
+			expected: []Segment{
+				{Text: "ds", Path: "/beans/bean/@id", LineNum: 2},
+				{Text: "password=p4ss", Path: "/beans/bean/property[@name='password']/@value", LineNum: 3},
+				{Text: "token=t0ken", Path: "/beans/bean/property[@name='token']/value", LineNum: 4},
+				{Text: "first", Path: "/beans/bean/description", LineNum: 5},
+				{Text: "second <raw>", Path: "/beans/bean/description", LineNum: 6},
+			},
+		},
+		{
+			name: "key attributes containing quotes",
+			content: `<appSettings>
+  <add key="Bob's key" value="b0b"/>
+  <add key="Bob's &quot;key&quot;" value="b0b2"/>
+</appSettings>`,
+			expected: []Segment{
+				{Text: "Bob's key=b0b", Path: `/appSettings/add[@key="Bob's key"]/@value`, LineNum: 2},
+				{Text: `Bob's "key"=b0b2`, Path: `/appSettings/add[@key=concat('Bob', "'", 's "key"')]/@value`, LineNum: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := extractFromXML([]byte(tt.content))
+			assert.NoError(t, err)
+			for _, expected := range tt.expected {
+				assert.Contains(t, segments, expected)
+			}
+		})
+	}
+}